@@ -0,0 +1,503 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brianvoe/sjwt"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// sessionCookie is the name of the cookie holding the session token for the web dashboard
+const sessionCookie = "lighthouse_session"
+
+// publicPaths can be requested without authentication
+var publicPaths = map[string]bool{
+	"/login":   true,
+	"/logout":  true,
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// Auth handles user accounts, API keys and sessions for the HTTP API
+type Auth struct {
+	Settings *Settings
+	DB       Store
+}
+
+// HashPassword returns the bcrypt hash of the password
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// NewAPIKey generates a random API key, and returns the key and the hash that is stored
+func NewAPIKey() (key string, keyHash string, err error) {
+	b := make([]byte, 32)
+	_, err = rand.Read(b)
+	if err != nil {
+		return "", "", err
+	}
+	key = "lh_" + hex.EncodeToString(b)
+	return key, hashAPIKey(key), nil
+}
+
+// hashAPIKey hashes the API key, the keys are random so a fast hash is fine
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// minPasswordLength is the shortest password accepted for a user account
+const minPasswordLength = 8
+
+// EnsureAdminUser creates the admin user from the settings if it doesn't exist, the admin
+// manages the other users through the API
+func (a *Auth) EnsureAdminUser() error {
+	_, found, err := a.DB.GetUserByUsername(a.Settings.Auth.AdminUsername)
+	if err != nil {
+		return err
+	}
+	if found {
+		return nil
+	}
+
+	hash, err := HashPassword(a.Settings.Auth.AdminPassword)
+	if err != nil {
+		return err
+	}
+	_, err = a.DB.CreateUser(a.Settings.Auth.AdminUsername, hash)
+	if err != nil {
+		return err
+	}
+	log.Println("Created admin user", a.Settings.Auth.AdminUsername)
+	return nil
+}
+
+// Middleware requires every request to be authenticated, except for the public paths
+// and the web dashboard files, as they don't contain any data
+// Requests are authenticated by an API key or a session token in the Authorization
+// header, or a session token in the session cookie, the public paths are authenticated
+// too when credentials are given, as /healthz and /readyz only show details to users
+func (a *Auth) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user, found, err := a.authenticate(c)
+		if a.isPublic(c.Request()) {
+			if err == nil && found {
+				c.Set("user", user)
+			}
+			return next(c)
+		}
+
+		if err == errNoCredentials {
+			return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+		}
+		if err != nil {
+			c.Logger().Error("error authenticating request: ", err.Error())
+			return echo.NewHTTPError(http.StatusInternalServerError, "unable to authenticate request")
+		}
+		if !found {
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid credentials")
+		}
+
+		c.Set("user", user)
+		return next(c)
+	}
+}
+
+// errNoCredentials is returned by authenticate when the request has no credentials
+var errNoCredentials = errors.New("no credentials")
+
+// authenticate returns the user of the API key or session token of the request
+func (a *Auth) authenticate(c echo.Context) (User, bool, error) {
+	token := ""
+	if header := c.Request().Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		token = strings.TrimPrefix(header, "Bearer ")
+	} else if cookie, err := c.Cookie(sessionCookie); err == nil {
+		token = cookie.Value
+	}
+	if token == "" {
+		return User{}, false, errNoCredentials
+	}
+
+	if strings.HasPrefix(token, "lh_") {
+		return a.DB.GetUserByAPIKeyHash(hashAPIKey(token))
+	}
+	return a.userFromSession(token)
+}
+
+// isPublic checks if the request can be made without authentication
+func (a *Auth) isPublic(req *http.Request) bool {
+	if publicPaths[req.URL.Path] {
+		return true
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	if req.URL.Path == "/" {
+		return true
+	}
+	_, err := webFiles.Open("web/" + strings.TrimPrefix(req.URL.Path, "/"))
+	return err == nil
+}
+
+// newSession creates a signed session token for the user
+func (a *Auth) newSession(user User) (token string, expires time.Time) {
+	expires = time.Now().Add(time.Duration(a.Settings.Auth.SessionHours) * time.Hour)
+	claims := sjwt.New()
+	claims.SetSubject(user.Username)
+	claims.SetIssuedAt(time.Now())
+	claims.SetExpiresAt(expires)
+	return claims.Generate([]byte(a.Settings.Auth.JWTSecret)), expires
+}
+
+// userFromSession verifies the session token, and returns the user it belongs to
+func (a *Auth) userFromSession(token string) (User, bool, error) {
+	if !sjwt.Verify(token, []byte(a.Settings.Auth.JWTSecret)) {
+		return User{}, false, nil
+	}
+	claims, err := sjwt.Parse(token)
+	if err != nil {
+		return User{}, false, nil
+	}
+	if claims.Validate() != nil {
+		return User{}, false, nil
+	}
+	username, err := claims.GetSubject()
+	if err != nil {
+		return User{}, false, nil
+	}
+	return a.DB.GetUserByUsername(username)
+}
+
+// LoginRequest is the body for POST /login
+type LoginRequest struct {
+	Username string `json:"username" form:"username"`
+	Password string `json:"password" form:"password"`
+}
+
+// LoginResponse is the result returned when calling POST /login
+type LoginResponse struct {
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+// HandlePOSTLogin checks the username and password, and returns a session token, which
+// is also set as a cookie for the web dashboard
+func (a *Auth) HandlePOSTLogin(c echo.Context) error {
+	req := LoginRequest{}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid login request")
+	}
+
+	user, found, err := a.DB.GetUserByUsername(req.Username)
+	if err != nil {
+		c.Logger().Error("error getting user: ", err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "unable to log in")
+	}
+	if !found || bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)) != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid username or password")
+	}
+
+	token, expires := a.newSession(user)
+	c.SetCookie(&http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   c.Scheme() == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	return c.JSON(http.StatusOK, LoginResponse{Token: token, Expires: expires})
+}
+
+// HandlePOSTLogout clears the session cookie
+func (a *Auth) HandlePOSTLogout(c echo.Context) error {
+	c.SetCookie(&http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+	return c.NoContent(http.StatusNoContent)
+}
+
+// CreateAPIKeyRequest is the body for POST /apikeys
+type CreateAPIKeyRequest struct {
+	Name string `json:"name" form:"name"`
+}
+
+// CreateAPIKeyResponse is the result returned when calling POST /apikeys, the key is
+// only returned here, as only the hash is stored
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// HandleGETAPIKeys returns the API keys of the logged in user
+func (a *Auth) HandleGETAPIKeys(c echo.Context) error {
+	user, ok := c.Get("user").(User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+	}
+
+	keys, err := a.DB.GetAPIKeys(user.Id)
+	if err != nil {
+		c.Logger().Error("error getting api keys: ", err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "unable to get api keys")
+	}
+	return c.JSON(http.StatusOK, keys)
+}
+
+// HandlePOSTAPIKeys creates a new API key for the logged in user
+func (a *Auth) HandlePOSTAPIKeys(c echo.Context) error {
+	user, ok := c.Get("user").(User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+	}
+
+	req := CreateAPIKeyRequest{}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid api key request")
+	}
+
+	key, keyHash, err := NewAPIKey()
+	if err != nil {
+		c.Logger().Error("error generating api key: ", err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "unable to create api key")
+	}
+	id, err := a.DB.CreateAPIKey(user.Id, req.Name, keyHash)
+	if err != nil {
+		c.Logger().Error("error saving api key: ", err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "unable to create api key")
+	}
+
+	res := CreateAPIKeyResponse{Key: key}
+	res.Id = id
+	res.Name = req.Name
+	res.CreatedAt = time.Now().UTC()
+	return c.JSON(http.StatusCreated, res)
+}
+
+// HandleDELETEAPIKey deletes an API key of the logged in user
+func (a *Auth) HandleDELETEAPIKey(c echo.Context) error {
+	user, ok := c.Get("user").(User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid api key id")
+	}
+
+	deleted, err := a.DB.DeleteAPIKey(user.Id, id)
+	if err != nil {
+		c.Logger().Error("error deleting api key: ", err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "unable to delete api key")
+	}
+	if !deleted {
+		return echo.NewHTTPError(http.StatusNotFound, "unknown api key")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// isAdmin checks if the user is the admin user given in the settings
+func (a *Auth) isAdmin(user User) bool {
+	return user.Username == a.Settings.Auth.AdminUsername
+}
+
+// requireAdmin returns the logged in user, or a HTTP error if the user isn't the admin
+func (a *Auth) requireAdmin(c echo.Context) (User, error) {
+	user, ok := c.Get("user").(User)
+	if !ok {
+		return user, echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+	}
+	if !a.isAdmin(user) {
+		return user, echo.NewHTTPError(http.StatusForbidden, "only the admin user can manage users")
+	}
+	return user, nil
+}
+
+// UserResponse is a user returned when calling GET /users
+type UserResponse struct {
+	User
+	Admin   bool     `json:"admin"`
+	Tenants []string `json:"tenants"` // the tenants the user can see, every tenant for the admin
+}
+
+// CreateUserRequest is the body for POST /users
+type CreateUserRequest struct {
+	Username string `json:"username" form:"username"`
+	Password string `json:"password" form:"password"`
+}
+
+// SetPasswordRequest is the body for PUT /users/:username/password, users changing their own
+// password must give the current password
+type SetPasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" form:"currentPassword"`
+	Password        string `json:"password" form:"password"`
+}
+
+// HandleGETUsers returns every user, and the tenants they can see, only for the admin
+func (a *Auth) HandleGETUsers(c echo.Context) error {
+	if _, err := a.requireAdmin(c); err != nil {
+		return err
+	}
+
+	users, err := a.DB.GetUsers()
+	if err != nil {
+		c.Logger().Error("error getting users: ", err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "unable to get users")
+	}
+
+	res := make([]UserResponse, 0, len(users))
+	for _, u := range users {
+		r := UserResponse{User: u, Admin: a.isAdmin(u), Tenants: a.Settings.TenantsForUser(u.Username)}
+		if r.Admin {
+			r.Tenants = make([]string, 0)
+			for _, t := range a.Settings.TenantList() {
+				r.Tenants = append(r.Tenants, t.Name)
+			}
+		}
+		res = append(res, r)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// HandlePOSTUsers creates a user, only for the admin, the tenants the user can see are given
+// by Tenants.Users in the settings
+func (a *Auth) HandlePOSTUsers(c echo.Context) error {
+	if _, err := a.requireAdmin(c); err != nil {
+		return err
+	}
+
+	req := CreateUserRequest{}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid user request")
+	}
+	if req.Username == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "username is required")
+	}
+	if len(req.Password) < minPasswordLength {
+		return echo.NewHTTPError(http.StatusBadRequest, "password must be at least "+strconv.Itoa(minPasswordLength)+" characters")
+	}
+
+	_, found, err := a.DB.GetUserByUsername(req.Username)
+	if err != nil {
+		c.Logger().Error("error getting user: ", err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "unable to create user")
+	}
+	if found {
+		return echo.NewHTTPError(http.StatusConflict, "user already exists")
+	}
+
+	hash, err := HashPassword(req.Password)
+	if err != nil {
+		c.Logger().Error("error hashing password: ", err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "unable to create user")
+	}
+	id, err := a.DB.CreateUser(req.Username, hash)
+	if err != nil {
+		c.Logger().Error("error saving user: ", err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "unable to create user")
+	}
+
+	user := User{Id: id, Username: req.Username, CreatedAt: time.Now().UTC()}
+	return c.JSON(http.StatusCreated, UserResponse{User: user, Tenants: a.Settings.TenantsForUser(user.Username)})
+}
+
+// HandleDELETEUser deletes a user and the API keys of the user, only for the admin, the admin
+// user itself can't be deleted
+func (a *Auth) HandleDELETEUser(c echo.Context) error {
+	if _, err := a.requireAdmin(c); err != nil {
+		return err
+	}
+
+	username := c.Param("username")
+	if username == a.Settings.Auth.AdminUsername {
+		return echo.NewHTTPError(http.StatusBadRequest, "the admin user can't be deleted")
+	}
+
+	deleted, err := a.DB.DeleteUser(username)
+	if err != nil {
+		c.Logger().Error("error deleting user: ", err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "unable to delete user")
+	}
+	if !deleted {
+		return echo.NewHTTPError(http.StatusNotFound, "unknown user")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// HandlePUTUserPassword changes the password of a user, the admin can change every password,
+// other users only their own, and they must give their current password
+func (a *Auth) HandlePUTUserPassword(c echo.Context) error {
+	user, ok := c.Get("user").(User)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+	}
+
+	username := c.Param("username")
+	if !a.isAdmin(user) && username != user.Username {
+		return echo.NewHTTPError(http.StatusForbidden, "you can only change your own password")
+	}
+
+	req := SetPasswordRequest{}
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid password request")
+	}
+	if len(req.Password) < minPasswordLength {
+		return echo.NewHTTPError(http.StatusBadRequest, "password must be at least "+strconv.Itoa(minPasswordLength)+" characters")
+	}
+	if !a.isAdmin(user) && bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.CurrentPassword)) != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "the current password is wrong")
+	}
+
+	hash, err := HashPassword(req.Password)
+	if err != nil {
+		c.Logger().Error("error hashing password: ", err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "unable to change password")
+	}
+	updated, err := a.DB.SetUserPassword(username, hash)
+	if err != nil {
+		c.Logger().Error("error saving password: ", err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "unable to change password")
+	}
+	if !updated {
+		return echo.NewHTTPError(http.StatusNotFound, "unknown user")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// validateAuth checks the authentication settings
+func validateAuth(s *Settings) []error {
+	var errs []error
+	if !s.Auth.Enabled {
+		return nil
+	}
+	if len(s.Auth.JWTSecret) < 32 {
+		errs = append(errs, errors.New("auth JWTSecret must be at least 32 characters"))
+	}
+	// the admin is the only one who can log in until other users are created
+	if s.Auth.AdminUsername == "" {
+		errs = append(errs, errors.New("auth AdminUsername must be configured when auth is enabled"))
+	}
+	if len(s.Auth.AdminPassword) < minPasswordLength {
+		errs = append(errs, errors.New("auth AdminPassword must be at least "+strconv.Itoa(minPasswordLength)+" characters"))
+	}
+	if s.Auth.SessionHours == 0 {
+		s.Auth.SessionHours = 24
+	}
+	return errs
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// newTestServer creates the API with authentication enabled, the admin user admin, and
+// the user bob, who can see the tenant home
+func newTestServer(t *testing.T) (*Settings, Store, *echo.Echo) {
+	settings, db := newTestStore(t)
+	settings.NorlysAPI.Sectors = []string{"DK1"}
+	settings.Pricing.VATPercent = 25
+	settings.Auth.Enabled = true
+	settings.Auth.JWTSecret = strings.Repeat("s", 32)
+	settings.Auth.SessionHours = 24
+	settings.Auth.AdminUsername = "admin"
+	settings.Auth.AdminPassword = "admin-password"
+	settings.Tenants = []Tenant{{Name: "home", LighthouseToken: "token", Users: []string{"bob"}}}
+
+	e, err := newServer(settings, db)
+	if err != nil {
+		t.Fatalf("newServer returned an error: %v", err)
+	}
+	hash, err := HashPassword("bob-password")
+	if err != nil {
+		t.Fatalf("unable to hash password: %v", err)
+	}
+	if _, err := db.CreateUser("bob", hash); err != nil {
+		t.Fatalf("unable to create user: %v", err)
+	}
+	return settings, db, e
+}
+
+// request makes the request towards the server, token is sent as a bearer token if given
+func request(e *echo.Echo, method string, path string, token string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+// login returns a session token for the user
+func login(t *testing.T, e *echo.Echo, username string, password string) string {
+	rec := request(e, http.MethodPost, "/login", "", `{"username": "`+username+`", "password": "`+password+`"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("login as %s returned %d: %s", username, rec.Code, rec.Body.String())
+	}
+	res := LoginResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("invalid login response: %v", err)
+	}
+	return res.Token
+}
+
+// createAPIKey creates an API key for the user of the token, and returns the id and the key
+func createAPIKey(t *testing.T, e *echo.Echo, token string) (int64, string) {
+	rec := request(e, http.MethodPost, "/apikeys", token, `{"name": "test"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("creating api key returned %d: %s", rec.Code, rec.Body.String())
+	}
+	res := CreateAPIKeyResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("invalid api key response: %v", err)
+	}
+	return res.Id, res.Key
+}
+
+func TestAuthLogin(t *testing.T) {
+	_, _, e := newTestServer(t)
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{name: "admin", body: `{"username": "admin", "password": "admin-password"}`, wantCode: http.StatusOK},
+		{name: "user", body: `{"username": "bob", "password": "bob-password"}`, wantCode: http.StatusOK},
+		{name: "bad password", body: `{"username": "bob", "password": "admin-password"}`, wantCode: http.StatusUnauthorized},
+		{name: "unknown user", body: `{"username": "eve", "password": "bob-password"}`, wantCode: http.StatusUnauthorized},
+		{name: "no password", body: `{"username": "bob"}`, wantCode: http.StatusUnauthorized},
+		{name: "invalid body", body: `{"username": `, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := request(e, http.MethodPost, "/login", "", tt.body)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("got %d, want %d: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			if rec.Code == http.StatusOK && !strings.Contains(rec.Header().Get("Set-Cookie"), sessionCookie+"=") {
+				t.Errorf("no session cookie set: %s", rec.Header().Get("Set-Cookie"))
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	settings, db, e := newTestServer(t)
+	session := login(t, e, "bob", "bob-password")
+	revokedId, revokedKey := createAPIKey(t, e, session)
+	if rec := request(e, http.MethodDelete, "/apikeys/"+strconv.FormatInt(revokedId, 10), session, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("deleting api key returned %d", rec.Code)
+	}
+	_, key := createAPIKey(t, e, session)
+
+	// a session signed with another secret
+	other := &Auth{Settings: &Settings{}, DB: db}
+	other.Settings.Auth.JWTSecret = strings.Repeat("x", 32)
+	other.Settings.Auth.SessionHours = 24
+	forged, _ := other.newSession(User{Username: "admin"})
+
+	// a session that has expired
+	expired := &Auth{Settings: settings, DB: db}
+	settings.Auth.SessionHours = -1
+	expiredSession, _ := expired.newSession(User{Username: "bob"})
+	settings.Auth.SessionHours = 24
+
+	tests := []struct {
+		name     string
+		token    string
+		cookie   string
+		wantCode int
+	}{
+		{name: "no credentials", wantCode: http.StatusUnauthorized},
+		{name: "session", token: session, wantCode: http.StatusOK},
+		{name: "session cookie", cookie: session, wantCode: http.StatusOK},
+		{name: "api key", token: key, wantCode: http.StatusOK},
+		{name: "revoked api key", token: revokedKey, wantCode: http.StatusUnauthorized},
+		{name: "unknown api key", token: "lh_" + strings.Repeat("0", 64), wantCode: http.StatusUnauthorized},
+		{name: "session signed with another secret", token: forged, wantCode: http.StatusUnauthorized},
+		{name: "expired session", token: expiredSession, wantCode: http.StatusUnauthorized},
+		{name: "garbage", token: "garbage", wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/meteringpoints", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: sessionCookie, Value: tt.cookie})
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Errorf("got %d, want %d: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthPublicPaths(t *testing.T) {
+	_, _, e := newTestServer(t)
+
+	tests := []struct {
+		method     string
+		path       string
+		wantPublic bool
+	}{
+		{method: http.MethodGet, path: "/", wantPublic: true},
+		{method: http.MethodGet, path: "/app.js", wantPublic: true},
+		{method: http.MethodGet, path: "/style.css", wantPublic: true},
+		{method: http.MethodPost, path: "/login", wantPublic: true},
+		{method: http.MethodPost, path: "/logout", wantPublic: true},
+		{method: http.MethodGet, path: "/healthz", wantPublic: true},
+		{method: http.MethodGet, path: "/readyz", wantPublic: true},
+		{method: http.MethodPost, path: "/", wantPublic: false},
+		{method: http.MethodPost, path: "/app.js", wantPublic: false},
+		{method: http.MethodGet, path: "/missing.js", wantPublic: false},
+		{method: http.MethodGet, path: "/../auth.go", wantPublic: false},
+		{method: http.MethodGet, path: "/usage", wantPublic: false},
+		{method: http.MethodGet, path: "/prices", wantPublic: false},
+		{method: http.MethodGet, path: "/metrics", wantPublic: false},
+		{method: http.MethodGet, path: "/users", wantPublic: false},
+		{method: http.MethodGet, path: "/apikeys", wantPublic: false},
+	}
+
+	for _, tt := range tests {
+		// the handlers of the public paths may also return 401, as for a login without a password
+		rec := request(e, tt.method, tt.path, "", "")
+		public := rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "authentication required")
+		if public != tt.wantPublic {
+			t.Errorf("%s %s returned %d, want public %v", tt.method, tt.path, rec.Code, tt.wantPublic)
+		}
+	}
+}
+
+func TestAuthUserManagement(t *testing.T) {
+	_, db, e := newTestServer(t)
+	admin := login(t, e, "admin", "admin-password")
+	bob := login(t, e, "bob", "bob-password")
+	adminKeyId, _ := createAPIKey(t, e, admin)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		body     string
+		wantCode int
+	}{
+		{name: "user lists users", method: http.MethodGet, path: "/users", token: bob, wantCode: http.StatusForbidden},
+		{name: "user creates a user", method: http.MethodPost, path: "/users", token: bob, body: `{"username": "eve", "password": "eve-password"}`, wantCode: http.StatusForbidden},
+		{name: "user deletes a user", method: http.MethodDelete, path: "/users/admin", token: bob, wantCode: http.StatusForbidden},
+		{name: "user changes the password of another user", method: http.MethodPut, path: "/users/admin/password", token: bob, body: `{"currentPassword": "admin-password", "password": "new-password"}`, wantCode: http.StatusForbidden},
+		{name: "user changes own password with a wrong current password", method: http.MethodPut, path: "/users/bob/password", token: bob, body: `{"currentPassword": "wrong-password", "password": "new-password"}`, wantCode: http.StatusForbidden},
+		{name: "user deletes an api key of another user", method: http.MethodDelete, path: "/apikeys/" + strconv.FormatInt(adminKeyId, 10), token: bob, wantCode: http.StatusNotFound},
+		{name: "admin lists users", method: http.MethodGet, path: "/users", token: admin, wantCode: http.StatusOK},
+		{name: "admin creates a user with a short password", method: http.MethodPost, path: "/users", token: admin, body: `{"username": "eve", "password": "short"}`, wantCode: http.StatusBadRequest},
+		{name: "admin creates a user", method: http.MethodPost, path: "/users", token: admin, body: `{"username": "eve", "password": "eve-password"}`, wantCode: http.StatusCreated},
+		{name: "admin creates an existing user", method: http.MethodPost, path: "/users", token: admin, body: `{"username": "eve", "password": "eve-password"}`, wantCode: http.StatusConflict},
+		{name: "admin deletes the admin", method: http.MethodDelete, path: "/users/admin", token: admin, wantCode: http.StatusBadRequest},
+		{name: "admin deletes an unknown user", method: http.MethodDelete, path: "/users/mallory", token: admin, wantCode: http.StatusNotFound},
+		{name: "admin deletes a user", method: http.MethodDelete, path: "/users/eve", token: admin, wantCode: http.StatusNoContent},
+		{name: "user changes own password", method: http.MethodPut, path: "/users/bob/password", token: bob, body: `{"currentPassword": "bob-password", "password": "new-password"}`, wantCode: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := request(e, tt.method, tt.path, tt.token, tt.body)
+			if rec.Code != tt.wantCode {
+				t.Errorf("got %d, want %d: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+		})
+	}
+
+	// the admin is still there, and the API key of the admin wasn't deleted by bob
+	adminUser, found, _ := db.GetUserByUsername("admin")
+	if !found {
+		t.Fatal("the admin user was deleted")
+	}
+	if keys, _ := db.GetAPIKeys(adminUser.Id); len(keys) != 1 {
+		t.Errorf("the admin has %d api keys, want 1", len(keys))
+	}
+	if rec := request(e, http.MethodPost, "/login", "", `{"username": "bob", "password": "new-password"}`); rec.Code != http.StatusOK {
+		t.Errorf("login with the new password returned %d", rec.Code)
+	}
+}
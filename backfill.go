@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// backfillPriceDays is the number of days of prices requested at once when backfilling
+const backfillPriceDays = 90
+
+// BackfillOptions selects what to backfill
+type BackfillOptions struct {
+	Source string    // eloverblik or prices
+	From   time.Time // start of the period, inclusive
+	To     time.Time // end of the period, exclusive
+}
+
+// runBackfillCommand runs `lighthouse backfill`, and returns the exit code
+func runBackfillCommand(args []string) int {
+	flags := newFlagSet("backfill")
+	from := flags.String("from", "", "start of the period, as 2006-01-02")
+	to := flags.String("to", "", "end of the period, as 2006-01-02, exclusive (default: today)")
+	source := flags.String("source", "", "what to backfill: eloverblik or prices")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	opts, err := parseBackfillOptions(*source, *from, *to)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		flags.Usage()
+		return exitUsage
+	}
+
+	settings, db, code := loadSettingsAndStore()
+	if code != exitOK {
+		return code
+	}
+	defer db.Close()
+
+	// stop after the current chunk on SIGINT or SIGTERM, the backfill is resumed by running it again
+	ctx, stop := signalContext()
+	defer stop()
+
+	err = Backfill(ctx, settings, db, opts)
+	if err != nil {
+		log.Println("Backfill failed:", err.Error())
+		return exitFailure
+	}
+	log.Println("Backfill done")
+	return exitOK
+}
+
+// parseBackfillOptions validates the backfill flags, the dates are danish dates
+func parseBackfillOptions(source string, from string, to string) (BackfillOptions, error) {
+	opts := BackfillOptions{Source: source}
+	if source != "eloverblik" && source != "prices" {
+		return opts, errors.New("--source must be eloverblik or prices")
+	}
+
+	if from == "" {
+		return opts, errors.New("--from is required")
+	}
+	var err error
+	opts.From, err = parseDateFlag("from", from, time.Time{})
+	if err != nil {
+		return opts, err
+	}
+	now := time.Now().In(danishTime)
+	opts.To, err = parseDateFlag("to", to, time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, danishTime))
+	if err != nil {
+		return opts, err
+	}
+	if !opts.From.Before(opts.To) {
+		return opts, errors.New("--from must be before --to")
+	}
+	return opts, nil
+}
+
+// Backfill loads the history of the source between from and to, chunk by chunk
+// The progress is stored after each chunk, so running the backfill again with the same --from
+// continues where it stopped, also when --to has moved, as it defaults to today
+func Backfill(ctx context.Context, settings *Settings, db Store, opts BackfillOptions) error {
+	switch opts.Source {
+	case "eloverblik":
+		for _, tenant := range settings.TenantList() {
+			err := backfillEloverblik(ctx, settings, db, tenant, opts)
+			if err != nil {
+				return errors.New("tenant " + tenant.Name + ": " + err.Error())
+			}
+		}
+		return nil
+	case "prices":
+		return backfillPrices(ctx, settings, db, opts)
+	}
+	return errors.New("unknown backfill source: " + opts.Source)
+}
+
+// backfillEloverblik loads the readings of each of the meteringpoints of the tenant
+func backfillEloverblik(ctx context.Context, settings *Settings, db Store, tenant Tenant, opts BackfillOptions) error {
+	eo := &ElOverblik{Tenant: tenant.Name, Retry: settings.ElOverblik.Retry, TokenDir: settings.DataDir()}
+	err := eo.SetApplicationToken(tenant.LighthouseToken)
+	if err != nil {
+		return err
+	}
+	err = eo.GetRequestToken(ctx, false, settings.SaveRequestTokenToDisk)
+	if err != nil {
+		return err
+	}
+
+	mps, err := eo.GetMeteringPoints(ctx)
+	if err != nil {
+		return err
+	}
+	for i := range mps {
+		mps[i].Sector = SectorForMeteringPoint(settings, mps[i])
+	}
+	err = db.SaveMeteringPoints(tenant.Name, &mps)
+	if err != nil {
+		return err
+	}
+
+	for _, mp := range mps {
+		err = backfillChunks(ctx, db, opts, mp.MeteringPointId, settings.ElOverblik.MaxDaysPerRequest, func(chunk DateRange) error {
+			return SaveMeteringReadings(ctx, settings, db, eo, mp.MeteringPointId, chunk.From, chunk.To)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillPrices loads the spot prices of each of the sectors, the norlys API only has
+// the latest prices, so they are always loaded from Energi Data Service
+func backfillPrices(ctx context.Context, settings *Settings, db Store, opts BackfillOptions) error {
+	eds := &EnergiDataService{URL: settings.EnergiDataService.URL, HistoryURL: settings.EnergiDataService.HistoryURL, Retry: settings.EnergiDataService.Retry}
+
+	for _, sector := range settings.NorlysAPI.Sectors {
+		err := backfillChunks(ctx, db, opts, sector, backfillPriceDays, func(chunk DateRange) error {
+			prices, err := eds.GetPricesBetween(ctx, sector, chunk.From, chunk.To)
+			if err != nil {
+				return err
+			}
+			for _, pd := range prices {
+				err = db.SaveNorlysPricingResult(&pd)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillChunks calls load for each chunk of at most days days, skipping the chunks done by
+// an earlier run, and logs the progress
+func backfillChunks(ctx context.Context, db Store, opts BackfillOptions, target string, days int, load func(chunk DateRange) error) error {
+	from := opts.From
+	doneUntil, found, err := db.GetBackfillProgress(opts.Source, target, opts.From)
+	if err != nil {
+		return err
+	}
+	if found {
+		if !doneUntil.Before(opts.To) {
+			log.Println("Backfill of", opts.Source, "for", target, "is already done")
+			return nil
+		}
+		log.Println("Resuming backfill of", opts.Source, "for", target, "from", doneUntil.In(danishTime).Format("2006-01-02"))
+		from = doneUntil.In(danishTime)
+	}
+
+	total := opts.To.Sub(opts.From)
+	for _, chunk := range splitDateRange(from, opts.To, days) {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		err = load(chunk)
+		if err != nil {
+			return err
+		}
+		err = db.SaveBackfillProgress(opts.Source, target, opts.From, opts.To, chunk.To)
+		if err != nil {
+			return err
+		}
+
+		done := chunk.To.Sub(opts.From)
+		log.Printf("Backfill of %s for %s: %s to %s done (%.0f%%)\n", opts.Source, target,
+			chunk.From.In(danishTime).Format("2006-01-02"), chunk.To.In(danishTime).Format("2006-01-02"), 100*float64(done)/float64(total))
+	}
+	return nil
+}
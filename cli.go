@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// Exit codes returned by the commands, so they can be used from scripts
+const (
+	exitOK       = 0 // the command succeeded
+	exitFailure  = 1 // the command failed, e.g. an upstream API or a database query returned an error
+	exitUsage    = 2 // unknown command or invalid flags
+	exitConfig   = 3 // the configuration file is missing or invalid
+	exitDatabase = 4 // unable to connect to or migrate the database
+)
+
+// usage is printed by `lighthouse help`, and when the command is unknown
+const usage = `Usage: lighthouse [command] [--config lighthouse.toml] [flags]
+
+Commands:
+  serve          collect data in the background and serve the API (default)
+  collect        fetch prices and eloverblik data once, and exit
+  migrate        apply the database migrations, and exit
+  backfill       load the history of eloverblik data or prices
+  token status   show when the eloverblik tokens expires
+  export         write the stored usage or prices as CSV or JSON
+  config check   list every invalid field of the configuration
+  help           show this help
+
+Run lighthouse <command> -h to see the flags of the command.
+
+The configuration file is given by --config or LIGHTHOUSE_CONFIG, otherwise lighthouse.toml is
+searched for in the working directory, next to the application, in the XDG config directories
+and in /etc/lighthouse. Every setting can be overridden by an environment variable named by its
+path, e.g. LIGHTHOUSE_DATABASE_PASSWORD or LIGHTHOUSE_TENANTS_0_LIGHTHOUSETOKEN.
+Relative paths, such as the SQLite database, are relative to the directory of the configuration
+file, or the working directory when there is no configuration file.
+`
+
+// runCommand runs the command given by the arguments, and returns the exit code
+func runCommand(args []string) int {
+	// serve is the default command, also when only flags are given, e.g. lighthouse --config x
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") && !isHelpFlag(args[0]) {
+		return runServeCommand(args)
+	}
+
+	switch args[0] {
+	case "serve":
+		return runServeCommand(args[1:])
+	case "collect":
+		return runCollectCommand(args[1:])
+	case "migrate":
+		return runMigrateCommand(args[1:])
+	case "backfill":
+		return runBackfillCommand(args[1:])
+	case "token":
+		if len(args) > 1 && args[1] == "status" {
+			return runTokenStatusCommand(args[2:])
+		}
+	case "export":
+		return runExportCommand(args[1:])
+	case "config":
+		if len(args) > 1 && args[1] == "check" {
+			return runConfigCheckCommand(args[2:])
+		}
+	case "help":
+		fmt.Print(usage)
+		return exitOK
+	}
+	if isHelpFlag(args[0]) {
+		fmt.Print(usage)
+		return exitOK
+	}
+
+	fmt.Fprint(os.Stderr, usage)
+	return exitUsage
+}
+
+// isHelpFlag checks if the argument asks for the usage
+func isHelpFlag(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help"
+}
+
+// newFlagSet creates the flag set for a command, with the --config flag shared by all commands
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.StringVar(&configPath, "config", "", "the configuration file (default: LIGHTHOUSE_CONFIG, or the first "+filename+" in the search path)")
+	return flags
+}
+
+// loadSettings reads the configuration file, and returns the exit code to use if it fails
+func loadSettings() (*Settings, int) {
+	settings := &Settings{}
+	err := settings.ReadConfigurationFile()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return nil, exitConfig
+	}
+	return settings, exitOK
+}
+
+// loadSettingsAndStore reads the configuration file and connects to the database, and returns
+// the exit code to use if either fails
+func loadSettingsAndStore() (*Settings, Store, int) {
+	settings, code := loadSettings()
+	if code != exitOK {
+		return nil, nil, code
+	}
+	db, err := NewStore(settings)
+	if err != nil {
+		log.Println("error connecting to db:", err.Error())
+		return nil, nil, exitDatabase
+	}
+	return settings, db, exitOK
+}
+
+// signalContext returns a context that is cancelled on SIGINT or SIGTERM
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// runCollectCommand runs `lighthouse collect`, which fetches the data once, for running from cron
+func runCollectCommand(args []string) int {
+	flags := newFlagSet("collect")
+	source := flags.String("source", "all", "what to collect: all, prices or eloverblik")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *source != "all" && *source != "prices" && *source != "eloverblik" {
+		fmt.Fprintln(os.Stderr, "--source must be all, prices or eloverblik")
+		return exitUsage
+	}
+
+	settings, db, code := loadSettingsAndStore()
+	if code != exitOK {
+		return code
+	}
+	defer db.Close()
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	code = exitOK
+	if *source == "all" || *source == "prices" {
+		err := CollectPrices(ctx, settings, db, NewPriceProvider(settings))
+		if err != nil {
+			code = exitFailure
+		}
+	}
+	if *source == "all" || *source == "eloverblik" {
+		for _, tenant := range settings.TenantList() {
+			eo := &ElOverblik{Tenant: tenant.Name, Retry: settings.ElOverblik.Retry, TokenDir: settings.DataDir()}
+			err := eo.SetApplicationToken(tenant.LighthouseToken)
+			if err == nil {
+				err = CollectEloverblik(ctx, settings, db, eo)
+			}
+			if err != nil {
+				log.Println("ERROR: tenant", tenant.Name+":", err.Error())
+				code = exitFailure
+			}
+		}
+	}
+	return code
+}
+
+// runConfigCheckCommand runs `lighthouse config check`, which reads the configuration and lists
+// every invalid field, it returns exitConfig if any are invalid
+func runConfigCheckCommand(args []string) int {
+	flags := newFlagSet("config check")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	// invalid environment variables are listed with the invalid settings
+	settings := &Settings{}
+	var errs []error
+	err := settings.Load()
+	if envErrs, ok := err.(ConfigErrors); ok {
+		errs = envErrs
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return exitConfig
+	}
+	if settings.ConfigFile != "" {
+		fmt.Println("Configuration file:", settings.ConfigFile)
+	} else {
+		fmt.Println("Configuration file: none, using environment variables only")
+	}
+
+	errs = append(errs, settings.Validate()...)
+	if len(errs) > 0 {
+		fmt.Println(len(errs), "invalid settings:")
+		for _, err := range errs {
+			fmt.Println("  " + err.Error())
+		}
+		return exitConfig
+	}
+	fmt.Println("The configuration is valid")
+	return exitOK
+}
+
+// runMigrateCommand runs `lighthouse migrate`, the migrations are applied when connecting
+func runMigrateCommand(args []string) int {
+	flags := newFlagSet("migrate")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	_, db, code := loadSettingsAndStore()
+	if code != exitOK {
+		return code
+	}
+	defer db.Close()
+
+	log.Println("The database schema is up to date")
+	return exitOK
+}
+
+// runTokenStatusCommand runs `lighthouse token status`, it returns exitFailure if any of the
+// application tokens are invalid, or expires within --warn-days days
+func runTokenStatusCommand(args []string) int {
+	flags := newFlagSet("token status")
+	warnDays := flags.Int("warn-days", 0, "fail if an application token expires within this many days")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	settings, code := loadSettings()
+	if code != exitOK {
+		return code
+	}
+
+	code = exitOK
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TENANT\tAPPLICATION TOKEN\tREQUEST TOKEN")
+	for _, tenant := range settings.TenantList() {
+		eo := &ElOverblik{Tenant: tenant.Name, TokenDir: settings.DataDir()}
+		application := ""
+		err := eo.SetApplicationToken(tenant.LighthouseToken)
+		if err != nil {
+			application = "invalid: " + err.Error()
+			code = exitFailure
+		} else {
+			expire, _ := eo.TokenExpiry()
+			application = "expires " + expire.In(danishTime).Format("2006-01-02 15:04")
+			if time.Until(expire) < time.Duration(*warnDays)*24*time.Hour {
+				code = exitFailure
+			}
+		}
+
+		request := "not saved"
+		if settings.SaveRequestTokenToDisk {
+			request = requestTokenStatus(eo)
+		}
+		fmt.Fprintln(w, tenant.Name+"\t"+application+"\t"+request)
+	}
+	w.Flush()
+	return code
+}
+
+// requestTokenStatus describes the request token saved on disk for the account
+func requestTokenStatus(eo *ElOverblik) string {
+	tokenJson, found, err := eo.ReadRequestTokenFromDisk()
+	if !found {
+		return "none"
+	}
+	if err != nil {
+		return "unreadable: " + err.Error()
+	}
+	var token struct {
+		Token  string
+		Expire time.Time
+	}
+	err = json.Unmarshal(tokenJson, &token)
+	if err != nil {
+		return "unreadable: " + err.Error()
+	}
+	if token.Expire.Before(time.Now()) {
+		return "expired " + token.Expire.In(danishTime).Format("2006-01-02 15:04")
+	}
+	return "expires " + token.Expire.In(danishTime).Format("2006-01-02 15:04")
+}
+
+// parseDateFlag parses a danish date given as 2006-01-02, def is returned if value is empty
+func parseDateFlag(name string, value string, def time.Time) (time.Time, error) {
+	if value == "" {
+		return def, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, danishTime)
+	if err != nil {
+		return t, errors.New("--" + name + " must be a date, as 2006-01-02")
+	}
+	return t, nil
+}
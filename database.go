@@ -1,15 +1,22 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 )
 
-// Database is used to connect and execute database queries
+// priceCurrency is the currency of the prices used when querying priceData, as the
+// prices for each currency are stored separately
+const priceCurrency = "DKK"
+
+// Database is used to connect and execute database queries against MySQL,
+// the queries are also used by the SQLiteDatabase
 type Database struct {
 	handle *sql.DB
 }
@@ -17,12 +24,27 @@ type Database struct {
 // ConnectToDatabase connects to database
 func (db *Database) ConnectToDatabase(settings *Settings) error {
 	var err error
-	db.handle, err = sql.Open("mysql", settings.Database.Username+":"+settings.Database.Password+"@tcp("+settings.Database.HostName+":3306)/lighthouse?parseTime=true")
+	db.handle, err = sql.Open("mysql", settings.Database.Username+":"+settings.Database.Password+"@tcp("+settings.Database.HostName+":3306)/"+settings.Database.Name+"?parseTime=true")
 	if err != nil {
 		log.Println("ERROR connecting to mysql database:", err.Error())
 		return err
 	}
-	return nil
+	return db.Migrate()
+}
+
+// Migrate applies the MySQL schema migrations
+func (db *Database) Migrate() error {
+	return runMigrations(db.handle, "mysql")
+}
+
+// Ping checks that the database is reachable
+func (db *Database) Ping(ctx context.Context) error {
+	return db.handle.PingContext(ctx)
+}
+
+// Close closes the connection to the database
+func (db *Database) Close() error {
+	return db.handle.Close()
 }
 
 // SaveNorlysPricingResult saves the norlys pricedata to database
@@ -36,26 +58,24 @@ func (db *Database) SaveNorlysPricingResult(pd *NorlysPricingResult) error {
 			d := pd.PriceDate.Add(time.Duration(t) * time.Hour)
 
 			SQL := "REPLACE INTO priceData (priceDate,sector,currency,hour,price) VALUES (?,?,?,?,?)"
-			rows, err := db.handle.Query(SQL, pd.PriceDate, pd.Sector, pd.Currency, d, p.Value)
+			_, err := db.handle.Exec(SQL, pd.PriceDate.UTC(), pd.Sector, pd.Currency, d.UTC(), p.Value)
 			if err != nil {
 				return err
 			}
-			if rows.Close() != nil {
-				log.Println("unable to close database rows.")
-			}
 		}
 	}
 
 	return nil
 }
 
-// SaveMeteringPoints saves each of the provided meteringpoints into to database
-func (db *Database) SaveMeteringPoints(mps *[]EloverblikMeteringPoint) error {
+// SaveMeteringPoints saves each of the provided meteringpoints into to database, and
+// associates them with the tenant
+func (db *Database) SaveMeteringPoints(tenant string, mps *[]EloverblikMeteringPoint) error {
 
 	// insert each of the meteringspoints into database
 	for _, mp := range *mps {
 		// Prepare the INSERT statement
-		var stmt, err = db.handle.Prepare("REPLACE INTO meteringPoint (streetCode, streetName, buildingNumber, floorId, roomId, citySubDivisionName, municipalityCode, locationDescription, settlementMethod, meterReadingOccurrence, firstConsumerPartyName, secondConsumerPartyName, meterNumber, consumerStartDate, meteringPointId, typeOfMp, balanceSupplierName, postcode, cityName, hasRelation) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		var stmt, err = db.handle.Prepare("REPLACE INTO meteringPoint (streetCode, streetName, buildingNumber, floorId, roomId, citySubDivisionName, municipalityCode, locationDescription, settlementMethod, meterReadingOccurrence, firstConsumerPartyName, secondConsumerPartyName, meterNumber, consumerStartDate, meteringPointId, typeOfMp, balanceSupplierName, postcode, cityName, hasRelation, sector) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -75,13 +95,14 @@ func (db *Database) SaveMeteringPoints(mps *[]EloverblikMeteringPoint) error {
 			mp.FirstConsumerPartyName,
 			mp.SecondConsumerPartyName,
 			mp.MeterNumber,
-			mp.ConsumerStartDate,
+			mp.ConsumerStartDate.UTC(),
 			mp.MeteringPointId,
 			mp.TypeOfMP,
 			mp.BalanceSupplierName,
 			mp.Postcode,
 			mp.CityName,
 			mp.HasRelation,
+			mp.Sector,
 		)
 		if err != nil {
 			return err
@@ -90,6 +111,11 @@ func (db *Database) SaveMeteringPoints(mps *[]EloverblikMeteringPoint) error {
 		if err != nil {
 			return err
 		}
+
+		_, err = db.handle.Exec("REPLACE INTO tenantMeteringPoint (tenant, meteringPointId) VALUES (?, ?)", tenant, mp.MeteringPointId)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -111,8 +137,10 @@ func (db *Database) SaveMeteringTimeSeries(mts EloverblikMeteringTimeSeriesResul
 					if err != nil {
 						return err
 					}
-					cHour := p.TimeInterval.Start.Add(time.Duration(pos) * time.Hour)
-					_, err = stmt.Exec(ts.MRID, ts.MeasurementUnitName, ts.BusinessType, cHour, point.OutQuantityQuantity, point.OutQuantityQuality)
+					// positions start at 1, and position 1 is the hour starting at TimeInterval.Start
+					// Versions before the schema migrations stored the hours one hour late, see the readme
+					cHour := p.TimeInterval.Start.Add(time.Duration(pos-1) * time.Hour)
+					_, err = stmt.Exec(ts.MRID, ts.MeasurementUnitName, ts.BusinessType, cHour.UTC(), point.OutQuantityQuantity, point.OutQuantityQuality)
 					if err != nil {
 						log.Fatal(err)
 						return err
@@ -127,3 +155,485 @@ func (db *Database) SaveMeteringTimeSeries(mts EloverblikMeteringTimeSeriesResul
 	}
 	return nil
 }
+
+// SaveCharges saves the tariffs, with their prices, and the subscriptions and fees for a meteringpoint
+func (db *Database) SaveCharges(charges EloverblikCharges) error {
+	mpId := charges.MeteringPointId
+
+	for _, t := range charges.Tariffs {
+		SQL := "REPLACE INTO meteringPointTariff (meteringPointId,name,owner,validFromDate,validToDate,description,periodType) VALUES (?,?,?,?,?,?,?)"
+		_, err := db.handle.Exec(SQL, mpId, t.Name, t.Owner, t.ValidFromDate.UTC(), nullableUTC(t.ValidToDate), t.Description, t.PeriodType)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range t.Prices {
+			pos, err := strconv.Atoi(p.Position)
+			if err != nil {
+				return err
+			}
+			SQL = "REPLACE INTO meteringPointTariffPrice (meteringPointId,name,owner,validFromDate,position,price) VALUES (?,?,?,?,?,?)"
+			_, err = db.handle.Exec(SQL, mpId, t.Name, t.Owner, t.ValidFromDate.UTC(), pos, p.Price)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, sub := range charges.Subscriptions {
+		SQL := "REPLACE INTO meteringPointSubscription (meteringPointId,name,owner,validFromDate,validToDate,description,periodType,price,quantity) VALUES (?,?,?,?,?,?,?,?,?)"
+		_, err := db.handle.Exec(SQL, mpId, sub.Name, sub.Owner, sub.ValidFromDate.UTC(), nullableUTC(sub.ValidToDate), sub.Description, sub.PeriodType, sub.Price, sub.Quantity)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, fee := range charges.Fees {
+		SQL := "REPLACE INTO meteringPointFee (meteringPointId,name,owner,validFromDate,validToDate,description,periodType,price,quantity) VALUES (?,?,?,?,?,?,?,?,?)"
+		_, err := db.handle.Exec(SQL, mpId, fee.Name, fee.Owner, fee.ValidFromDate.UTC(), nullableUTC(fee.ValidToDate), fee.Description, fee.PeriodType, fee.Price, fee.Quantity)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// nullableUTC converts an optional time to UTC, or nil so it's stored as NULL
+func nullableUTC(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+// MeteringTimeSeriesEntry is a single hourly reading from the meteringPointsTimeSeries table
+type MeteringTimeSeriesEntry struct {
+	MeteringPointId string
+	MeasurementUnit string
+	BusinessType    string
+	Hour            time.Time
+	Quantity        float64
+	Quality         string
+}
+
+// MeteringPointExists checks if the provided meteringpoint is stored in the database
+func (db *Database) MeteringPointExists(meteringPointId string) (bool, error) {
+	var count int
+	err := db.handle.QueryRow("SELECT COUNT(*) FROM meteringPoint WHERE meteringPointId = ?", meteringPointId).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// MeteringPointSummary is the stored information about a meteringpoint, without the personal details
+type MeteringPointSummary struct {
+	MeteringPointId string `json:"meteringPointId"`
+	TypeOfMP        string `json:"typeOfMP"`
+	StreetName      string `json:"streetName"`
+	BuildingNumber  string `json:"buildingNumber"`
+	Postcode        string `json:"postcode"`
+	CityName        string `json:"cityName"`
+	Sector          string `json:"sector"`
+}
+
+// GetMeteringPoints returns the stored meteringpoints belonging to the tenants, or all of them if tenants is nil
+func (db *Database) GetMeteringPoints(tenants []string) ([]MeteringPointSummary, error) {
+	res := make([]MeteringPointSummary, 0)
+	if tenants != nil && len(tenants) == 0 {
+		return res, nil
+	}
+
+	SQL := "SELECT meteringPointId, typeOfMp, streetName, buildingNumber, postcode, cityName, sector FROM meteringPoint"
+	args := make([]interface{}, 0, len(tenants))
+	if tenants != nil {
+		SQL += " WHERE meteringPointId IN (SELECT meteringPointId FROM tenantMeteringPoint WHERE tenant IN (?" + strings.Repeat(",?", len(tenants)-1) + "))"
+		for _, t := range tenants {
+			args = append(args, t)
+		}
+	}
+	SQL += " ORDER BY meteringPointId"
+
+	rows, err := db.handle.Query(SQL, args...)
+	if err != nil {
+		return res, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		mp := MeteringPointSummary{}
+		err = rows.Scan(&mp.MeteringPointId, &mp.TypeOfMP, &mp.StreetName, &mp.BuildingNumber, &mp.Postcode, &mp.CityName, &mp.Sector)
+		if err != nil {
+			return res, err
+		}
+		res = append(res, mp)
+	}
+
+	return res, rows.Err()
+}
+
+// GetMeteringPointTenants returns the tenants the meteringpoint belongs to
+func (db *Database) GetMeteringPointTenants(meteringPointId string) ([]string, error) {
+	res := make([]string, 0)
+
+	rows, err := db.handle.Query("SELECT tenant FROM tenantMeteringPoint WHERE meteringPointId = ? ORDER BY tenant", meteringPointId)
+	if err != nil {
+		return res, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tenant string
+		err = rows.Scan(&tenant)
+		if err != nil {
+			return res, err
+		}
+		res = append(res, tenant)
+	}
+
+	return res, rows.Err()
+}
+
+// GetMeteringPointSector returns the price sector stored for the meteringpoint, which
+// is empty if the sector isn't known
+func (db *Database) GetMeteringPointSector(meteringPointId string) (string, error) {
+	var sector string
+	err := db.handle.QueryRow("SELECT sector FROM meteringPoint WHERE meteringPointId = ?", meteringPointId).Scan(&sector)
+	if err != nil {
+		return "", err
+	}
+	return sector, nil
+}
+
+// GetMeteringTimeSeries returns the hourly readings for the meteringpoint, where from <= hour < to
+func (db *Database) GetMeteringTimeSeries(meteringPointId string, from time.Time, to time.Time) ([]MeteringTimeSeriesEntry, error) {
+	res := make([]MeteringTimeSeriesEntry, 0)
+
+	SQL := "SELECT meteringPointId,measurementUnit,businessType,hour,quantity,quality FROM meteringPointsTimeSeries WHERE meteringPointId = ? AND hour >= ? AND hour < ? ORDER BY hour"
+	rows, err := db.handle.Query(SQL, meteringPointId, from.UTC(), to.UTC())
+	if err != nil {
+		return res, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e := MeteringTimeSeriesEntry{}
+		err = rows.Scan(&e.MeteringPointId, &e.MeasurementUnit, &e.BusinessType, &e.Hour, &e.Quantity, &e.Quality)
+		if err != nil {
+			return res, err
+		}
+		res = append(res, e)
+	}
+
+	return res, rows.Err()
+}
+
+// GetMeteringHighWaterMark returns the latest hour synced from eloverblik for the meteringpoint,
+// found is false if the meteringpoint hasn't been synced yet
+func (db *Database) GetMeteringHighWaterMark(meteringPointId string) (lastHour time.Time, found bool, err error) {
+	err = db.handle.QueryRow("SELECT lastHour FROM meteringPointSync WHERE meteringPointId = ?", meteringPointId).Scan(&lastHour)
+	if err == sql.ErrNoRows {
+		return lastHour, false, nil
+	}
+	if err != nil {
+		return lastHour, false, err
+	}
+	return lastHour, true, nil
+}
+
+// SaveMeteringHighWaterMark stores the latest hour synced from eloverblik for the meteringpoint
+func (db *Database) SaveMeteringHighWaterMark(meteringPointId string, lastHour time.Time) error {
+	_, err := db.handle.Exec("REPLACE INTO meteringPointSync (meteringPointId,lastHour,updatedAt) VALUES (?,?,?)", meteringPointId, lastHour.UTC(), time.Now().UTC())
+	return err
+}
+
+// GetBackfillProgress returns how far the backfills of the source and target starting at from have
+// come, with any end date, as the default end date moves every day, found is false if no backfill
+// has been started
+func (db *Database) GetBackfillProgress(source string, target string, from time.Time) (doneUntil time.Time, found bool, err error) {
+	SQL := "SELECT doneUntil FROM backfillProgress WHERE source = ? AND target = ? AND fromDate = ? ORDER BY doneUntil DESC LIMIT 1"
+	err = db.handle.QueryRow(SQL, source, target, from.UTC()).Scan(&doneUntil)
+	if err == sql.ErrNoRows {
+		return doneUntil, false, nil
+	}
+	if err != nil {
+		return doneUntil, false, err
+	}
+	return doneUntil, true, nil
+}
+
+// SaveBackfillProgress stores how far the backfill of the source and target between from and to has come
+func (db *Database) SaveBackfillProgress(source string, target string, from time.Time, to time.Time, doneUntil time.Time) error {
+	SQL := "REPLACE INTO backfillProgress (source,target,fromDate,toDate,doneUntil,updatedAt) VALUES (?,?,?,?,?,?)"
+	_, err := db.handle.Exec(SQL, source, target, from.UTC(), to.UTC(), doneUntil.UTC(), time.Now().UTC())
+	return err
+}
+
+// GetLatestMeteringReadings returns the latest stored hourly reading for each meteringpoint
+func (db *Database) GetLatestMeteringReadings() ([]MeteringTimeSeriesEntry, error) {
+	res := make([]MeteringTimeSeriesEntry, 0)
+
+	SQL := "SELECT ts.meteringPointId, ts.measurementUnit, ts.businessType, ts.hour, ts.quantity, ts.quality FROM meteringPointsTimeSeries ts " +
+		"JOIN (SELECT meteringPointId, MAX(hour) AS hour FROM meteringPointsTimeSeries GROUP BY meteringPointId) latest " +
+		"ON latest.meteringPointId = ts.meteringPointId AND latest.hour = ts.hour ORDER BY ts.meteringPointId"
+	rows, err := db.handle.Query(SQL)
+	if err != nil {
+		return res, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e := MeteringTimeSeriesEntry{}
+		err = rows.Scan(&e.MeteringPointId, &e.MeasurementUnit, &e.BusinessType, &e.Hour, &e.Quantity, &e.Quality)
+		if err != nil {
+			return res, err
+		}
+		res = append(res, e)
+	}
+
+	return res, rows.Err()
+}
+
+// HourlyCostEntry is the consumption for a single hour joined with the price for that hour
+type HourlyCostEntry struct {
+	Hour     time.Time
+	Quantity float64
+	Price    sql.NullFloat64 // price in øre/kWh, not valid if no price is stored for the hour
+}
+
+// GetHourlyCost returns the hourly consumption for the meteringpoint, where from <= hour < to,
+// together with the price from priceData in the given sector and priceCurrency for each hour
+func (db *Database) GetHourlyCost(meteringPointId string, sector string, from time.Time, to time.Time) ([]HourlyCostEntry, error) {
+	res := make([]HourlyCostEntry, 0)
+
+	SQL := "SELECT ts.hour, ts.quantity, p.price FROM meteringPointsTimeSeries ts LEFT JOIN priceData p ON p.hour = ts.hour AND p.sector = ? AND p.currency = ? WHERE ts.meteringPointId = ? AND ts.hour >= ? AND ts.hour < ? ORDER BY ts.hour"
+	rows, err := db.handle.Query(SQL, sector, priceCurrency, meteringPointId, from.UTC(), to.UTC())
+	if err != nil {
+		return res, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e := HourlyCostEntry{}
+		err = rows.Scan(&e.Hour, &e.Quantity, &e.Price)
+		if err != nil {
+			return res, err
+		}
+		res = append(res, e)
+	}
+
+	return res, rows.Err()
+}
+
+// SpotPrice is the price in øre/kWh for a single hour from the priceData table
+type SpotPrice struct {
+	Hour  time.Time
+	Price float64
+}
+
+// GetSpotPrices returns the stored prices in priceCurrency for the sector, where from <= hour < to
+func (db *Database) GetSpotPrices(sector string, from time.Time, to time.Time) ([]SpotPrice, error) {
+	res := make([]SpotPrice, 0)
+
+	SQL := "SELECT hour, price FROM priceData WHERE sector = ? AND currency = ? AND hour >= ? AND hour < ? AND price IS NOT NULL ORDER BY hour"
+	rows, err := db.handle.Query(SQL, sector, priceCurrency, from.UTC(), to.UTC())
+	if err != nil {
+		return res, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p := SpotPrice{}
+		err = rows.Scan(&p.Hour, &p.Price)
+		if err != nil {
+			return res, err
+		}
+		res = append(res, p)
+	}
+
+	return res, rows.Err()
+}
+
+// StoredTariff is a tariff for a meteringpoint, with the prices in DKK/kWh by position
+type StoredTariff struct {
+	Name          string
+	Owner         string
+	ValidFromDate time.Time
+	ValidToDate   sql.NullTime
+	Prices        map[int]float64
+}
+
+// GetTariffs returns the tariffs for the meteringpoint that are valid at some point between from and to
+func (db *Database) GetTariffs(meteringPointId string, from time.Time, to time.Time) ([]StoredTariff, error) {
+	res := make([]StoredTariff, 0)
+
+	SQL := "SELECT t.name, t.owner, t.validFromDate, t.validToDate, p.position, p.price FROM meteringPointTariff t " +
+		"JOIN meteringPointTariffPrice p ON p.meteringPointId = t.meteringPointId AND p.name = t.name AND p.owner = t.owner AND p.validFromDate = t.validFromDate " +
+		"WHERE t.meteringPointId = ? AND t.validFromDate < ? AND (t.validToDate IS NULL OR t.validToDate > ?) " +
+		"ORDER BY t.name, t.owner, t.validFromDate, p.position"
+	rows, err := db.handle.Query(SQL, meteringPointId, to.UTC(), from.UTC())
+	if err != nil {
+		return res, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t := StoredTariff{}
+		var position int
+		var price float64
+		err = rows.Scan(&t.Name, &t.Owner, &t.ValidFromDate, &t.ValidToDate, &position, &price)
+		if err != nil {
+			return res, err
+		}
+
+		// the rows are ordered by tariff, so the positions for a tariff are next to each other
+		last := len(res) - 1
+		if last >= 0 && res[last].Name == t.Name && res[last].Owner == t.Owner && res[last].ValidFromDate.Equal(t.ValidFromDate) {
+			res[last].Prices[position] = price
+			continue
+		}
+		t.Prices = map[int]float64{position: price}
+		res = append(res, t)
+	}
+
+	return res, rows.Err()
+}
+
+// User is a user account that is allowed to use the HTTP API
+type User struct {
+	Id           int64     `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"-"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// APIKey is an API key belonging to a user, only the hash of the key is stored
+type APIKey struct {
+	Id        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// CountUsers returns the number of user accounts
+func (db *Database) CountUsers() (int, error) {
+	var count int
+	err := db.handle.QueryRow("SELECT COUNT(*) FROM users").Scan(&count)
+	return count, err
+}
+
+// CreateUser creates a user account, and returns the id of the user
+func (db *Database) CreateUser(username string, passwordHash string) (int64, error) {
+	res, err := db.handle.Exec("INSERT INTO users (username, passwordHash, createdAt) VALUES (?, ?, ?)", username, passwordHash, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// GetUserByUsername returns the user with the username, found is false if there is no such user
+func (db *Database) GetUserByUsername(username string) (user User, found bool, err error) {
+	err = db.handle.QueryRow("SELECT id, username, passwordHash, createdAt FROM users WHERE username = ?", username).
+		Scan(&user.Id, &user.Username, &user.PasswordHash, &user.CreatedAt)
+	if err == sql.ErrNoRows {
+		return user, false, nil
+	}
+	return user, err == nil, err
+}
+
+// GetUsers returns every user account, ordered by username
+func (db *Database) GetUsers() ([]User, error) {
+	res := make([]User, 0)
+
+	rows, err := db.handle.Query("SELECT id, username, passwordHash, createdAt FROM users ORDER BY username")
+	if err != nil {
+		return res, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		u := User{}
+		err = rows.Scan(&u.Id, &u.Username, &u.PasswordHash, &u.CreatedAt)
+		if err != nil {
+			return res, err
+		}
+		res = append(res, u)
+	}
+
+	return res, rows.Err()
+}
+
+// SetUserPassword replaces the password hash of the user, updated is false if there is no such user
+func (db *Database) SetUserPassword(username string, passwordHash string) (updated bool, err error) {
+	res, err := db.handle.Exec("UPDATE users SET passwordHash = ? WHERE username = ?", passwordHash, username)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteUser deletes the user and the API keys of the user, deleted is false if there is no such user
+func (db *Database) DeleteUser(username string) (deleted bool, err error) {
+	_, err = db.handle.Exec("DELETE FROM apiKeys WHERE userId IN (SELECT id FROM users WHERE username = ?)", username)
+	if err != nil {
+		return false, err
+	}
+	res, err := db.handle.Exec("DELETE FROM users WHERE username = ?", username)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// GetUserByAPIKeyHash returns the user owning the API key, found is false if there is no such key
+func (db *Database) GetUserByAPIKeyHash(keyHash string) (user User, found bool, err error) {
+	err = db.handle.QueryRow("SELECT u.id, u.username, u.passwordHash, u.createdAt FROM apiKeys k JOIN users u ON u.id = k.userId WHERE k.keyHash = ?", keyHash).
+		Scan(&user.Id, &user.Username, &user.PasswordHash, &user.CreatedAt)
+	if err == sql.ErrNoRows {
+		return user, false, nil
+	}
+	return user, err == nil, err
+}
+
+// CreateAPIKey stores the hash of a new API key for the user, and returns the id of the key
+func (db *Database) CreateAPIKey(userId int64, name string, keyHash string) (int64, error) {
+	res, err := db.handle.Exec("INSERT INTO apiKeys (userId, name, keyHash, createdAt) VALUES (?, ?, ?, ?)", userId, name, keyHash, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// GetAPIKeys returns the API keys of the user
+func (db *Database) GetAPIKeys(userId int64) ([]APIKey, error) {
+	res := make([]APIKey, 0)
+
+	rows, err := db.handle.Query("SELECT id, name, createdAt FROM apiKeys WHERE userId = ? ORDER BY id", userId)
+	if err != nil {
+		return res, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		k := APIKey{}
+		err = rows.Scan(&k.Id, &k.Name, &k.CreatedAt)
+		if err != nil {
+			return res, err
+		}
+		res = append(res, k)
+	}
+
+	return res, rows.Err()
+}
+
+// DeleteAPIKey deletes the API key of the user, deleted is false if the user has no such key
+func (db *Database) DeleteAPIKey(userId int64, id int64) (deleted bool, err error) {
+	res, err := db.handle.Exec("DELETE FROM apiKeys WHERE userId = ? AND id = ?", userId, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestStore creates a migrated SQLite store in a temporary directory
+func newTestStore(t *testing.T) (*Settings, Store) {
+	settings := &Settings{}
+	settings.Database.Driver = "sqlite"
+	settings.Database.Path = filepath.Join(t.TempDir(), "lighthouse.db")
+	db, err := NewStore(settings)
+	if err != nil {
+		t.Fatalf("unable to create store: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return settings, db
+}
+
+// saveTestReadings stores a reading for each hour from start, as eloverblik returns them
+func saveTestReadings(t *testing.T, db Store, meteringPointId string, start time.Time, quantities ...float64) {
+	points := make([]string, 0, len(quantities))
+	for i, q := range quantities {
+		points = append(points, fmt.Sprintf(`{"position": "%d", "out_Quantity.quantity": "%v", "out_Quantity.quality": "A04"}`, i+1, q))
+	}
+	var mts EloverblikMeteringTimeSeriesResult
+	err := json.Unmarshal([]byte(fmt.Sprintf(`{"result": [{"success": true, "MyEnergyData_MarketDocument": {"TimeSeries": [{
+		"mRID": "%s", "businessType": "A04", "measurement_Unit.name": "KWH",
+		"Period": [{"resolution": "PT1H", "timeInterval": {"start": "%s"}, "Point": [%s]}]
+	}]}}]}`, meteringPointId, start.UTC().Format(time.RFC3339), strings.Join(points, ","))), &mts)
+	if err != nil {
+		t.Fatalf("invalid time series: %v", err)
+	}
+	if err := db.SaveMeteringTimeSeries(mts); err != nil {
+		t.Fatalf("unable to save readings: %v", err)
+	}
+}
+
+// saveTestPrices stores a spot price for each hour from start, with start as the price date
+func saveTestPrices(t *testing.T, db Store, sector string, start time.Time, prices ...float64) {
+	pd := NorlysPricingResult{PriceDate: start, Sector: sector, Currency: priceCurrency}
+	for i, price := range prices {
+		pd.DisplayPrices = append(pd.DisplayPrices, NorlysDisplayPrice{Time: strconv.Itoa(i), Value: price})
+	}
+	if err := db.SaveNorlysPricingResult(&pd); err != nil {
+		t.Fatalf("unable to save prices: %v", err)
+	}
+}
+
+func TestSaveMeteringTimeSeries(t *testing.T) {
+	_, db := newTestStore(t)
+
+	// eloverblik periods start at danish midnight, and position 1 is the first hour of the period
+	var mts EloverblikMeteringTimeSeriesResult
+	err := json.Unmarshal([]byte(`{"result": [{"success": true, "MyEnergyData_MarketDocument": {"TimeSeries": [{
+		"mRID": "571313100000000001", "businessType": "A04", "measurement_Unit.name": "KWH",
+		"Period": [
+			{"resolution": "PT1H", "timeInterval": {"start": "2025-11-09T23:00:00Z", "end": "2025-11-10T23:00:00Z"}, "Point": [
+				{"position": "1", "out_Quantity.quantity": "0.5", "out_Quantity.quality": "A04"},
+				{"position": "2", "out_Quantity.quantity": "0.6", "out_Quantity.quality": "A04"},
+				{"position": "24", "out_Quantity.quantity": "0.7", "out_Quantity.quality": "A04"}
+			]},
+			{"resolution": "PT1H", "timeInterval": {"start": "2025-10-25T22:00:00Z", "end": "2025-10-26T23:00:00Z"}, "Point": [
+				{"position": "1", "out_Quantity.quantity": "1.5", "out_Quantity.quality": "A04"},
+				{"position": "25", "out_Quantity.quantity": "1.6", "out_Quantity.quality": "A04"}
+			]}
+		]
+	}]}}]}`), &mts)
+	if err != nil {
+		t.Fatalf("invalid time series: %v", err)
+	}
+	err = db.SaveMeteringTimeSeries(mts)
+	if err != nil {
+		t.Fatalf("SaveMeteringTimeSeries returned an error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		day  time.Time // danish date
+		want map[int]float64
+	}{
+		{name: "position 1 is the start of the period", day: time.Date(2025, 11, 10, 0, 0, 0, 0, danishTime),
+			want: map[int]float64{0: 0.5, 1: 0.6, 23: 0.7}},
+		{name: "the long DST day", day: time.Date(2025, 10, 26, 0, 0, 0, 0, danishTime),
+			want: map[int]float64{0: 1.5, 24: 1.6}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := db.GetMeteringTimeSeries("571313100000000001", tt.day, tt.day.AddDate(0, 0, 1))
+			if err != nil {
+				t.Fatalf("GetMeteringTimeSeries returned an error: %v", err)
+			}
+			if len(entries) != len(tt.want) {
+				t.Fatalf("got %d readings, want %d", len(entries), len(tt.want))
+			}
+			for _, e := range entries {
+				hour := int(e.Hour.Sub(tt.day) / time.Hour)
+				want, ok := tt.want[hour]
+				if !ok || e.Quantity != want {
+					t.Errorf("got %v at hour %d (%v), want %v", e.Quantity, hour, e.Hour, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPricesAreStoredOncePerHour(t *testing.T) {
+	_, db := newTestStore(t)
+
+	// the same hours saved by two fetches with different price dates, as when both Norlys and
+	// Energi Data Service are used, or a later fetch covers the hours again
+	day := time.Date(2025, 11, 10, 0, 0, 0, 0, danishTime)
+	saveTestPrices(t, db, "DK1", day.Add(-24*time.Hour), 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 100, 100)
+	saveTestPrices(t, db, "DK1", day, 200, 200)
+	saveTestReadings(t, db, "571313100000000001", day, 1, 2)
+
+	prices, err := db.GetSpotPrices("DK1", day, day.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("GetSpotPrices returned an error: %v", err)
+	}
+	if len(prices) != 2 || prices[0].Price != 200 || prices[1].Price != 200 {
+		t.Errorf("got prices %+v, want 200 for each of the 2 hours", prices)
+	}
+
+	cost, err := db.GetHourlyCost("571313100000000001", "DK1", day, day.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("GetHourlyCost returned an error: %v", err)
+	}
+	if len(cost) != 2 || cost[0].Price.Float64 != 200 || cost[1].Price.Float64 != 200 {
+		t.Errorf("got hourly cost %+v, want 200 for each of the 2 hours", cost)
+	}
+}
+
+func TestPriceDataMigrationRemovesDuplicateHours(t *testing.T) {
+	handle, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "lighthouse.db"))
+	if err != nil {
+		t.Fatalf("unable to open database: %v", err)
+	}
+	defer handle.Close()
+
+	// apply the migrations before the priceData key was changed
+	migrations, err := loadMigrations("sqlite")
+	if err != nil {
+		t.Fatalf("unable to load migrations: %v", err)
+	}
+	_, err = handle.Exec("CREATE TABLE schema_migrations (version INTEGER NOT NULL PRIMARY KEY, appliedAt DATETIME NOT NULL)")
+	if err != nil {
+		t.Fatalf("unable to create schema_migrations: %v", err)
+	}
+	for _, m := range migrations {
+		if m.Version >= 10 {
+			break
+		}
+		for _, stmt := range strings.Split(m.SQL, ";") {
+			if strings.TrimSpace(stmt) == "" {
+				continue
+			}
+			if _, err := handle.Exec(stmt); err != nil {
+				t.Fatalf("migration %s failed: %v", m.Name, err)
+			}
+		}
+		if _, err := handle.Exec("INSERT INTO schema_migrations (version, appliedAt) VALUES (?, ?)", m.Version, time.Now().UTC()); err != nil {
+			t.Fatalf("unable to record migration %s: %v", m.Name, err)
+		}
+	}
+
+	hour := time.Date(2025, 11, 10, 12, 0, 0, 0, time.UTC)
+	rows := []struct {
+		priceDate time.Time
+		hour      time.Time
+		price     sql.NullFloat64
+	}{
+		{priceDate: hour.Add(-36 * time.Hour), hour: hour, price: sql.NullFloat64{Float64: 100, Valid: true}},
+		{priceDate: hour.Add(-12 * time.Hour), hour: hour, price: sql.NullFloat64{Float64: 200, Valid: true}},
+		{priceDate: hour.Add(-12 * time.Hour), hour: hour.Add(time.Hour), price: sql.NullFloat64{Float64: 300, Valid: true}},
+		{priceDate: hour, hour: hour.Add(time.Hour), price: sql.NullFloat64{}},
+	}
+	for _, r := range rows {
+		_, err = handle.Exec("INSERT INTO priceData (priceDate, sector, currency, hour, price) VALUES (?, 'DK1', 'DKK', ?, ?)", r.priceDate, r.hour, r.price)
+		if err != nil {
+			t.Fatalf("unable to insert price: %v", err)
+		}
+	}
+
+	if err := runMigrations(handle, "sqlite"); err != nil {
+		t.Fatalf("runMigrations returned an error: %v", err)
+	}
+
+	db := &Database{handle: handle}
+	prices, err := db.GetSpotPrices("DK1", hour, hour.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("GetSpotPrices returned an error: %v", err)
+	}
+	// the latest price is kept, unless it's missing
+	if len(prices) != 2 || prices[0].Price != 200 || prices[1].Price != 300 {
+		t.Errorf("got prices %+v, want 200 and 300", prices)
+	}
+}
@@ -0,0 +1,42 @@
+CREATE TABLE IF NOT EXISTS `priceData` (
+  `priceDate` datetime NOT NULL,
+  `sector` varchar(20) NOT NULL DEFAULT '',
+  `currency` varchar(20) NOT NULL DEFAULT '',
+  `hour` datetime NOT NULL,
+  `price` float DEFAULT NULL,
+  PRIMARY KEY (`priceDate`,`hour`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `meteringPoint` (
+  `meteringPointId` varchar(50) NOT NULL,
+  `streetCode` varchar(20) NOT NULL DEFAULT '',
+  `streetName` varchar(255) NOT NULL DEFAULT '',
+  `buildingNumber` varchar(20) NOT NULL DEFAULT '',
+  `floorId` int NOT NULL DEFAULT 0,
+  `roomId` int NOT NULL DEFAULT 0,
+  `citySubDivisionName` varchar(255) NOT NULL DEFAULT '',
+  `municipalityCode` varchar(20) NOT NULL DEFAULT '',
+  `locationDescription` varchar(255) NOT NULL DEFAULT '',
+  `settlementMethod` varchar(20) NOT NULL DEFAULT '',
+  `meterReadingOccurrence` varchar(20) NOT NULL DEFAULT '',
+  `firstConsumerPartyName` varchar(255) NOT NULL DEFAULT '',
+  `secondConsumerPartyName` varchar(255) NOT NULL DEFAULT '',
+  `meterNumber` varchar(50) NOT NULL DEFAULT '',
+  `consumerStartDate` datetime DEFAULT NULL,
+  `typeOfMp` varchar(20) NOT NULL DEFAULT '',
+  `balanceSupplierName` varchar(255) NOT NULL DEFAULT '',
+  `postcode` varchar(20) NOT NULL DEFAULT '',
+  `cityName` varchar(255) NOT NULL DEFAULT '',
+  `hasRelation` tinyint(1) NOT NULL DEFAULT 0,
+  PRIMARY KEY (`meteringPointId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `meteringPointsTimeSeries` (
+  `meteringPointId` varchar(50) NOT NULL,
+  `measurementUnit` varchar(20) NOT NULL DEFAULT '',
+  `businessType` varchar(20) NOT NULL DEFAULT '',
+  `hour` datetime NOT NULL,
+  `quantity` decimal(12,3) NOT NULL DEFAULT 0,
+  `quality` varchar(20) NOT NULL DEFAULT '',
+  PRIMARY KEY (`meteringPointId`,`hour`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
@@ -0,0 +1,46 @@
+CREATE TABLE IF NOT EXISTS `meteringPointTariff` (
+  `meteringPointId` varchar(50) NOT NULL,
+  `name` varchar(255) NOT NULL,
+  `owner` varchar(50) NOT NULL DEFAULT '',
+  `validFromDate` datetime NOT NULL,
+  `validToDate` datetime DEFAULT NULL,
+  `description` varchar(255) NOT NULL DEFAULT '',
+  `periodType` varchar(20) NOT NULL DEFAULT '',
+  PRIMARY KEY (`meteringPointId`,`name`,`owner`,`validFromDate`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `meteringPointTariffPrice` (
+  `meteringPointId` varchar(50) NOT NULL,
+  `name` varchar(255) NOT NULL,
+  `owner` varchar(50) NOT NULL DEFAULT '',
+  `validFromDate` datetime NOT NULL,
+  `position` int NOT NULL,
+  `price` decimal(12,6) NOT NULL DEFAULT 0,
+  PRIMARY KEY (`meteringPointId`,`name`,`owner`,`validFromDate`,`position`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `meteringPointSubscription` (
+  `meteringPointId` varchar(50) NOT NULL,
+  `name` varchar(255) NOT NULL,
+  `owner` varchar(50) NOT NULL DEFAULT '',
+  `validFromDate` datetime NOT NULL,
+  `validToDate` datetime DEFAULT NULL,
+  `description` varchar(255) NOT NULL DEFAULT '',
+  `periodType` varchar(20) NOT NULL DEFAULT '',
+  `price` decimal(12,6) NOT NULL DEFAULT 0,
+  `quantity` int NOT NULL DEFAULT 0,
+  PRIMARY KEY (`meteringPointId`,`name`,`owner`,`validFromDate`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `meteringPointFee` (
+  `meteringPointId` varchar(50) NOT NULL,
+  `name` varchar(255) NOT NULL,
+  `owner` varchar(50) NOT NULL DEFAULT '',
+  `validFromDate` datetime NOT NULL,
+  `validToDate` datetime DEFAULT NULL,
+  `description` varchar(255) NOT NULL DEFAULT '',
+  `periodType` varchar(20) NOT NULL DEFAULT '',
+  `price` decimal(12,6) NOT NULL DEFAULT 0,
+  `quantity` int NOT NULL DEFAULT 0,
+  PRIMARY KEY (`meteringPointId`,`name`,`owner`,`validFromDate`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
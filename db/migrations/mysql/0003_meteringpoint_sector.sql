@@ -0,0 +1,7 @@
+SET @addSector = (SELECT IF(COUNT(*) = 0, 'ALTER TABLE `meteringPoint` ADD COLUMN `sector` varchar(20) NOT NULL DEFAULT ''''', 'DO 0') FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'meteringPoint' AND COLUMN_NAME = 'sector');
+
+PREPARE addSector FROM @addSector;
+
+EXECUTE addSector;
+
+DEALLOCATE PREPARE addSector;
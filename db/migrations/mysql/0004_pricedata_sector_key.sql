@@ -0,0 +1 @@
+ALTER TABLE `priceData` DROP PRIMARY KEY, ADD PRIMARY KEY (`sector`,`currency`,`hour`,`priceDate`);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS `tenantMeteringPoint` (
+  `tenant` varchar(255) NOT NULL,
+  `meteringPointId` varchar(50) NOT NULL,
+  PRIMARY KEY (`tenant`,`meteringPointId`),
+  KEY `meteringPointId` (`meteringPointId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+INSERT IGNORE INTO `tenantMeteringPoint` (`tenant`, `meteringPointId`) SELECT 'default', `meteringPointId` FROM `meteringPoint`;
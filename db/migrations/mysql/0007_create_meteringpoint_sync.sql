@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS `meteringPointSync` (
+  `meteringPointId` varchar(50) NOT NULL,
+  `lastHour` datetime NOT NULL,
+  `updatedAt` datetime NOT NULL,
+  PRIMARY KEY (`meteringPointId`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+INSERT IGNORE INTO `meteringPointSync` (`meteringPointId`, `lastHour`, `updatedAt`) SELECT `meteringPointId`, MAX(`hour`), UTC_TIMESTAMP() FROM `meteringPointsTimeSeries` GROUP BY `meteringPointId`;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS `backfillProgress` (
+  `source` varchar(20) NOT NULL,
+  `target` varchar(50) NOT NULL,
+  `fromDate` datetime NOT NULL,
+  `toDate` datetime NOT NULL,
+  `doneUntil` datetime NOT NULL,
+  `updatedAt` datetime NOT NULL,
+  PRIMARY KEY (`source`,`target`,`fromDate`,`toDate`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
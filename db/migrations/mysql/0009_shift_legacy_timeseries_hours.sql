@@ -0,0 +1,4 @@
+-- This migration shifted every reading back one hour, which also moved the readings stored
+-- with the correct hour. It's kept as a no-op so the versions of the dialects stay aligned,
+-- the readme describes how to fix readings stored before the schema migrations
+DO 0;
@@ -0,0 +1,3 @@
+DELETE p FROM `priceData` p JOIN `priceData` q ON q.`sector` = p.`sector` AND q.`currency` = p.`currency` AND q.`hour` = p.`hour` AND ((q.`price` IS NOT NULL AND p.`price` IS NULL) OR ((q.`price` IS NULL) = (p.`price` IS NULL) AND q.`priceDate` > p.`priceDate`));
+
+ALTER TABLE `priceData` DROP PRIMARY KEY, ADD PRIMARY KEY (`sector`,`currency`,`hour`);
@@ -0,0 +1,42 @@
+CREATE TABLE IF NOT EXISTS priceData (
+  priceDate DATETIME NOT NULL,
+  sector TEXT NOT NULL DEFAULT '',
+  currency TEXT NOT NULL DEFAULT '',
+  hour DATETIME NOT NULL,
+  price REAL DEFAULT NULL,
+  PRIMARY KEY (priceDate, hour)
+);
+
+CREATE TABLE IF NOT EXISTS meteringPoint (
+  meteringPointId TEXT NOT NULL,
+  streetCode TEXT NOT NULL DEFAULT '',
+  streetName TEXT NOT NULL DEFAULT '',
+  buildingNumber TEXT NOT NULL DEFAULT '',
+  floorId INTEGER NOT NULL DEFAULT 0,
+  roomId INTEGER NOT NULL DEFAULT 0,
+  citySubDivisionName TEXT NOT NULL DEFAULT '',
+  municipalityCode TEXT NOT NULL DEFAULT '',
+  locationDescription TEXT NOT NULL DEFAULT '',
+  settlementMethod TEXT NOT NULL DEFAULT '',
+  meterReadingOccurrence TEXT NOT NULL DEFAULT '',
+  firstConsumerPartyName TEXT NOT NULL DEFAULT '',
+  secondConsumerPartyName TEXT NOT NULL DEFAULT '',
+  meterNumber TEXT NOT NULL DEFAULT '',
+  consumerStartDate DATETIME DEFAULT NULL,
+  typeOfMp TEXT NOT NULL DEFAULT '',
+  balanceSupplierName TEXT NOT NULL DEFAULT '',
+  postcode TEXT NOT NULL DEFAULT '',
+  cityName TEXT NOT NULL DEFAULT '',
+  hasRelation INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (meteringPointId)
+);
+
+CREATE TABLE IF NOT EXISTS meteringPointsTimeSeries (
+  meteringPointId TEXT NOT NULL,
+  measurementUnit TEXT NOT NULL DEFAULT '',
+  businessType TEXT NOT NULL DEFAULT '',
+  hour DATETIME NOT NULL,
+  quantity REAL NOT NULL DEFAULT 0,
+  quality TEXT NOT NULL DEFAULT '',
+  PRIMARY KEY (meteringPointId, hour)
+);
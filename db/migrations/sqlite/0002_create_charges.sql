@@ -0,0 +1,46 @@
+CREATE TABLE IF NOT EXISTS meteringPointTariff (
+  meteringPointId TEXT NOT NULL,
+  name TEXT NOT NULL,
+  owner TEXT NOT NULL DEFAULT '',
+  validFromDate DATETIME NOT NULL,
+  validToDate DATETIME DEFAULT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  periodType TEXT NOT NULL DEFAULT '',
+  PRIMARY KEY (meteringPointId, name, owner, validFromDate)
+);
+
+CREATE TABLE IF NOT EXISTS meteringPointTariffPrice (
+  meteringPointId TEXT NOT NULL,
+  name TEXT NOT NULL,
+  owner TEXT NOT NULL DEFAULT '',
+  validFromDate DATETIME NOT NULL,
+  position INTEGER NOT NULL,
+  price REAL NOT NULL DEFAULT 0,
+  PRIMARY KEY (meteringPointId, name, owner, validFromDate, position)
+);
+
+CREATE TABLE IF NOT EXISTS meteringPointSubscription (
+  meteringPointId TEXT NOT NULL,
+  name TEXT NOT NULL,
+  owner TEXT NOT NULL DEFAULT '',
+  validFromDate DATETIME NOT NULL,
+  validToDate DATETIME DEFAULT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  periodType TEXT NOT NULL DEFAULT '',
+  price REAL NOT NULL DEFAULT 0,
+  quantity INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (meteringPointId, name, owner, validFromDate)
+);
+
+CREATE TABLE IF NOT EXISTS meteringPointFee (
+  meteringPointId TEXT NOT NULL,
+  name TEXT NOT NULL,
+  owner TEXT NOT NULL DEFAULT '',
+  validFromDate DATETIME NOT NULL,
+  validToDate DATETIME DEFAULT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  periodType TEXT NOT NULL DEFAULT '',
+  price REAL NOT NULL DEFAULT 0,
+  quantity INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (meteringPointId, name, owner, validFromDate)
+);
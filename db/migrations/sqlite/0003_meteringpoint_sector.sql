@@ -0,0 +1 @@
+ALTER TABLE meteringPoint ADD COLUMN sector TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,14 @@
+CREATE TABLE priceData_new (
+  priceDate DATETIME NOT NULL,
+  sector TEXT NOT NULL DEFAULT '',
+  currency TEXT NOT NULL DEFAULT '',
+  hour DATETIME NOT NULL,
+  price REAL DEFAULT NULL,
+  PRIMARY KEY (sector, currency, hour, priceDate)
+);
+
+INSERT INTO priceData_new (priceDate, sector, currency, hour, price) SELECT priceDate, sector, currency, hour, price FROM priceData;
+
+DROP TABLE priceData;
+
+ALTER TABLE priceData_new RENAME TO priceData;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS users (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  username TEXT NOT NULL UNIQUE,
+  passwordHash TEXT NOT NULL,
+  createdAt DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS apiKeys (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  userId INTEGER NOT NULL,
+  name TEXT NOT NULL DEFAULT '',
+  keyHash TEXT NOT NULL UNIQUE,
+  createdAt DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS apiKeys_userId ON apiKeys (userId);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS tenantMeteringPoint (
+  tenant TEXT NOT NULL,
+  meteringPointId TEXT NOT NULL,
+  PRIMARY KEY (tenant, meteringPointId)
+);
+
+CREATE INDEX IF NOT EXISTS tenantMeteringPoint_meteringPointId ON tenantMeteringPoint (meteringPointId);
+
+INSERT INTO tenantMeteringPoint (tenant, meteringPointId) SELECT 'default', meteringPointId FROM meteringPoint;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS meteringPointSync (
+  meteringPointId TEXT NOT NULL,
+  lastHour DATETIME NOT NULL,
+  updatedAt DATETIME NOT NULL,
+  PRIMARY KEY (meteringPointId)
+);
+
+INSERT INTO meteringPointSync (meteringPointId, lastHour, updatedAt) SELECT meteringPointId, MAX(hour), CURRENT_TIMESTAMP FROM meteringPointsTimeSeries GROUP BY meteringPointId;
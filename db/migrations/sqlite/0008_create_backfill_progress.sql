@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS backfillProgress (
+  source TEXT NOT NULL,
+  target TEXT NOT NULL,
+  fromDate DATETIME NOT NULL,
+  toDate DATETIME NOT NULL,
+  doneUntil DATETIME NOT NULL,
+  updatedAt DATETIME NOT NULL,
+  PRIMARY KEY (source, target, fromDate, toDate)
+);
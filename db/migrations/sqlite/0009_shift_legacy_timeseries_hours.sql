@@ -0,0 +1,3 @@
+-- SQLite databases were never written with the legacy hours, this is a no-op so the versions
+-- of the dialects stay aligned
+SELECT 1;
@@ -0,0 +1,16 @@
+CREATE TABLE priceData_new (
+  priceDate DATETIME NOT NULL,
+  sector TEXT NOT NULL DEFAULT '',
+  currency TEXT NOT NULL DEFAULT '',
+  hour DATETIME NOT NULL,
+  price REAL DEFAULT NULL,
+  PRIMARY KEY (sector, currency, hour)
+);
+
+DELETE FROM priceData WHERE EXISTS (SELECT 1 FROM priceData q WHERE q.sector = priceData.sector AND q.currency = priceData.currency AND q.hour = priceData.hour AND ((q.price IS NOT NULL AND priceData.price IS NULL) OR ((q.price IS NULL) = (priceData.price IS NULL) AND q.priceDate > priceData.priceDate)));
+
+INSERT INTO priceData_new (priceDate, sector, currency, hour, price) SELECT priceDate, sector, currency, hour, price FROM priceData;
+
+DROP TABLE priceData;
+
+ALTER TABLE priceData_new RENAME TO priceData;
@@ -21,6 +21,9 @@ import (
 // it's able to fetch the metering timeSeries data
 type ElOverblik struct {
 	Lock             sync.RWMutex
+	Tenant           string      // the tenant the account belongs to, used to keep the request tokens apart
+	Retry            RetryPolicy // how requests are retried when eloverblik is rate limiting or unavailable
+	TokenDir         string      // the directory the request token is saved in, see Settings.DataDir
 	ApplicationToken struct {
 		Token  string
 		Expire time.Time
@@ -63,44 +66,59 @@ type EloverblikMeteringPoint struct {
 	ConsumerCVR             string        `json:"consumerCVR"`
 	DataAccessCVR           string        `json:"dataAccessCVR"`
 	ChildMeteringPoints     []interface{} `json:"childMeteringPoints"`
+
+	// Sector is the price sector of the meteringpoint, it's not part of the eloverblik response
+	Sector string `json:"-"`
 }
 
+// EloverblikGetChargesResult this is the result returned when calling the
+// /api/meteringpoints/meteringpoint/getcharges API call
 type EloverblikGetChargesResult struct {
 	Result []struct {
-		Result struct {
-			Fees            []interface{} `json:"fees"`
-			MeteringPointId string        `json:"meteringPointId"`
-			Subscriptions   []struct {
-				Price         float64     `json:"price"`
-				Quantity      int         `json:"quantity"`
-				Name          string      `json:"name"`
-				Description   string      `json:"description"`
-				Owner         string      `json:"owner"`
-				ValidFromDate time.Time   `json:"validFromDate"`
-				ValidToDate   interface{} `json:"validToDate"`
-				PeriodType    string      `json:"periodType"`
-			} `json:"subscriptions"`
-			Tariffs []struct {
-				Prices []struct {
-					Position string  `json:"position"`
-					Price    float64 `json:"price"`
-				} `json:"prices"`
-				Name          string      `json:"name"`
-				Description   string      `json:"description"`
-				Owner         string      `json:"owner"`
-				ValidFromDate time.Time   `json:"validFromDate"`
-				ValidToDate   interface{} `json:"validToDate"`
-				PeriodType    string      `json:"periodType"`
-			} `json:"tariffs"`
-		} `json:"result"`
-		Success    bool        `json:"success"`
-		ErrorCode  int         `json:"errorCode"`
-		ErrorText  string      `json:"errorText"`
-		Id         string      `json:"id"`
-		StackTrace interface{} `json:"stackTrace"`
+		Result     EloverblikCharges `json:"result"`
+		Success    bool              `json:"success"`
+		ErrorCode  int               `json:"errorCode"`
+		ErrorText  string            `json:"errorText"`
+		Id         string            `json:"id"`
+		StackTrace interface{}       `json:"stackTrace"`
 	} `json:"result"`
 }
 
+// EloverblikCharges holds the tariffs, subscriptions and fees for a meteringpoint
+type EloverblikCharges struct {
+	Fees            []EloverblikCharge `json:"fees"`
+	MeteringPointId string             `json:"meteringPointId"`
+	Subscriptions   []EloverblikCharge `json:"subscriptions"`
+	Tariffs         []EloverblikTariff `json:"tariffs"`
+}
+
+// EloverblikCharge is a fixed price subscription or fee, the price is in DKK
+type EloverblikCharge struct {
+	Price         float64    `json:"price"`
+	Quantity      int        `json:"quantity"`
+	Name          string     `json:"name"`
+	Description   string     `json:"description"`
+	Owner         string     `json:"owner"`
+	ValidFromDate time.Time  `json:"validFromDate"`
+	ValidToDate   *time.Time `json:"validToDate"`
+	PeriodType    string     `json:"periodType"`
+}
+
+// EloverblikTariff is a price per kWh, the prices are in DKK/kWh, and if the tariff
+// has more than one price, the position is the hour of the day starting at 1
+type EloverblikTariff struct {
+	Prices []struct {
+		Position string  `json:"position"`
+		Price    float64 `json:"price"`
+	} `json:"prices"`
+	Name          string     `json:"name"`
+	Description   string     `json:"description"`
+	Owner         string     `json:"owner"`
+	ValidFromDate time.Time  `json:"validFromDate"`
+	ValidToDate   *time.Time `json:"validToDate"`
+	PeriodType    string     `json:"periodType"`
+}
+
 type EloverblikMeteringTimeSeriesResult struct {
 	Result []struct {
 		MyEnergyDataMarketDocument struct {
@@ -148,6 +166,7 @@ type ElOverblikTokenResult struct {
 	Result string `json:"result"`
 }
 
+// EloverblikGetTimeSeriesRequest is the request body for the gettimeseries and getcharges API calls
 type EloverblikGetTimeSeriesRequest struct {
 	MeteringPoints struct {
 		MeteringPoint []string `json:"meteringPoint"`
@@ -174,18 +193,28 @@ func (eo *ElOverblik) SetApplicationToken(token string) error {
 		return errors.New("application token has expired")
 	}
 
+	eo.Lock.Lock()
 	eo.ApplicationToken.Token = token
 	eo.ApplicationToken.Expire = time.Unix(expire, 0)
+	eo.Lock.Unlock()
 	return nil
 }
 
+// TokenExpiry returns when the application token and the current request token expires,
+// the times are zero if the token hasn't been set
+func (eo *ElOverblik) TokenExpiry() (application time.Time, request time.Time) {
+	eo.Lock.RLock()
+	defer eo.Lock.RUnlock()
+	return eo.ApplicationToken.Expire, eo.RequestToken.Expire
+}
+
 // GetRequestToken is using the application token to get a request token
 // is a request is successfully, it updates the RequestToken struct in the
 // ElOverblik main struct
 // If the current Request token hasn't expired then it won't fetch a new one,
 // unless forceGetToken is set to true
 // There is a limitation on how many requests, you are allowed to call the eloverblik /api/token
-func (eo *ElOverblik) GetRequestToken(forceGetToken bool, saveToken bool) error {
+func (eo *ElOverblik) GetRequestToken(ctx context.Context, forceGetToken bool, saveToken bool) error {
 
 	// check if Application token is configured, and not expired
 	if eo.ApplicationToken.Token == "" {
@@ -202,7 +231,9 @@ func (eo *ElOverblik) GetRequestToken(forceGetToken bool, saveToken bool) error
 		if err == nil {
 			if tokenExits {
 				log.Println("Read request token from disk")
+				eo.Lock.Lock()
 				err = json.Unmarshal(jsonToken, &eo.RequestToken)
+				eo.Lock.Unlock()
 				if err != nil {
 					log.Println("Unable to use the request token from disk:", err.Error())
 				}
@@ -216,25 +247,22 @@ func (eo *ElOverblik) GetRequestToken(forceGetToken bool, saveToken bool) error
 		return nil
 	}
 
-	// create the context, timeout after 20 seconds
-	timeoutContext, cancelFunc := context.WithTimeout(context.Background(), 20*time.Second)
-	defer cancelFunc()
-
-	// create the request
-	req, err := http.NewRequestWithContext(timeoutContext, http.MethodGet, "https://api.eloverblik.dk/customerapi/api/token", nil)
-	if err != nil {
-		return err
-	}
-
-	// add the headers needed
-	req.Header.Add("accept", "application/json")
-	req.Header.Add("Authorization", "Bearer "+eo.ApplicationToken.Token)
+	// make the http request, retrying if eloverblik is rate limiting or unavailable
+	res, err := eo.Retry.Do(ctx, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.eloverblik.dk/customerapi/api/token", nil)
+		if err != nil {
+			return nil, err
+		}
 
-	// make the http request
-	res, err := http.DefaultClient.Do(req)
+		// add the headers needed
+		req.Header.Add("accept", "application/json")
+		req.Header.Add("Authorization", "Bearer "+eo.ApplicationToken.Token)
+		return req, nil
+	})
 	if err != nil {
 		return err
 	}
+	defer res.Body.Close()
 
 	if res.StatusCode > 299 {
 		return errors.New("unable to get request token, server responded:" + res.Status)
@@ -269,8 +297,10 @@ func (eo *ElOverblik) GetRequestToken(forceGetToken bool, saveToken bool) error
 		return errors.New("request token has expired")
 	}
 
+	eo.Lock.Lock()
 	eo.RequestToken.Token = tokenRes.Result
 	eo.RequestToken.Expire = time.Unix(expire, 0)
+	eo.Lock.Unlock()
 
 	// if configured let's save the request token to disk, there is a limitation on how many times
 	// this application is allowed to request a token
@@ -291,14 +321,8 @@ func (eo *ElOverblik) GetRequestToken(forceGetToken bool, saveToken bool) error
 
 // ReadRequestTokenFromDisk checks if there is a token located on disk, if so it returns it
 func (eo *ElOverblik) ReadRequestTokenFromDisk() (tokenJson []byte, tokenExisted bool, err error) {
-	// get application directory
-	dir, err := filepath.Abs(filepath.Dir(os.Args[0]))
-	if err != nil {
-		return tokenJson, false, err
-	}
-
 	// create the path
-	filename := filepath.Join(dir, ".requestToken")
+	filename := filepath.Join(eo.TokenDir, eo.requestTokenFilename())
 
 	// read the file
 	tokenJson, err = os.ReadFile(filename)
@@ -310,19 +334,22 @@ func (eo *ElOverblik) ReadRequestTokenFromDisk() (tokenJson []byte, tokenExisted
 
 }
 
-// SaveRequestTokenToDisk saves the provided token to .requestToken file
-func (eo *ElOverblik) SaveRequestTokenToDisk(token string) error {
-	// get application directory
-	dir, err := filepath.Abs(filepath.Dir(os.Args[0]))
-	if err != nil {
-		return err
+// requestTokenFilename returns the filename of the request token for the tenant, the
+// default tenant uses .requestToken, so tokens saved before tenants existed are reused
+func (eo *ElOverblik) requestTokenFilename() string {
+	if eo.Tenant == "" || eo.Tenant == defaultTenant {
+		return ".requestToken"
 	}
+	return ".requestToken-" + eo.Tenant
+}
 
+// SaveRequestTokenToDisk saves the provided token to the .requestToken file of the tenant
+func (eo *ElOverblik) SaveRequestTokenToDisk(token string) error {
 	// create the path
-	filename := filepath.Join(dir, ".requestToken")
+	filename := filepath.Join(eo.TokenDir, eo.requestTokenFilename())
 
 	// write the file
-	err = os.WriteFile(filename, []byte(token), 0644)
+	err := os.WriteFile(filename, []byte(token), 0644)
 	if err != nil {
 		return err
 	}
@@ -330,27 +357,24 @@ func (eo *ElOverblik) SaveRequestTokenToDisk(token string) error {
 }
 
 // GetMeteringPoints get the meteringpoints for the token provided, and returns an array with the result
-func (eo *ElOverblik) GetMeteringPoints() (Meteringpoints []EloverblikMeteringPoint, err error) {
-
-	// create the context, timeout after 20 seconds
-	timeoutContext, cancelFunc := context.WithTimeout(context.Background(), 20*time.Second)
-	defer cancelFunc()
+func (eo *ElOverblik) GetMeteringPoints(ctx context.Context) (Meteringpoints []EloverblikMeteringPoint, err error) {
 
-	// create the request
-	req, err := http.NewRequestWithContext(timeoutContext, http.MethodGet, "https://api.eloverblik.dk/customerapi/api/meteringpoints/meteringpoints?includeAll=true", nil)
-	if err != nil {
-		return make([]EloverblikMeteringPoint, 0), err
-	}
-
-	// add the headers needed
-	req.Header.Add("accept", "application/json")
-	req.Header.Add("Authorization", "Bearer "+eo.RequestToken.Token)
+	// make the http request, retrying if eloverblik is rate limiting or unavailable
+	res, err := eo.Retry.Do(ctx, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://api.eloverblik.dk/customerapi/api/meteringpoints/meteringpoints?includeAll=true", nil)
+		if err != nil {
+			return nil, err
+		}
 
-	// make the http request
-	res, err := http.DefaultClient.Do(req)
+		// add the headers needed
+		req.Header.Add("accept", "application/json")
+		req.Header.Add("Authorization", "Bearer "+eo.RequestToken.Token)
+		return req, nil
+	})
 	if err != nil {
 		return make([]EloverblikMeteringPoint, 0), err
 	}
+	defer res.Body.Close()
 
 	// check the HTTP status code
 	if res.StatusCode > 299 {
@@ -375,17 +399,13 @@ func (eo *ElOverblik) GetMeteringPoints() (Meteringpoints []EloverblikMeteringPo
 }
 
 // GetMeterReadings  make the "gettimeseries" request towards eloverblik and returns the result
-func (eo *ElOverblik) GetMeterReadings(meteringPoint string, fromDate time.Time, toDate time.Time) (result EloverblikMeteringTimeSeriesResult, err error) {
+func (eo *ElOverblik) GetMeterReadings(ctx context.Context, meteringPoint string, fromDate time.Time, toDate time.Time) (result EloverblikMeteringTimeSeriesResult, err error) {
 
 	// Get a string representation og the fromDate and toDate
 	seriesFrom := fromDate.Format("2006-01-02")
 	seriesTo := toDate.Format("2006-01-02")
 	log.Println("Getting data fromDate:", seriesFrom, "toDate:", seriesTo)
 
-	// create the context, timeout after 20 seconds
-	timeoutContext, cancelFunc := context.WithTimeout(context.Background(), 20*time.Second)
-	defer cancelFunc()
-
 	// create the json for the body
 	reqBody := EloverblikGetTimeSeriesRequest{}
 	reqBody.MeteringPoints.MeteringPoint = []string{meteringPoint}
@@ -394,22 +414,23 @@ func (eo *ElOverblik) GetMeterReadings(meteringPoint string, fromDate time.Time,
 		return result, err
 	}
 
-	// create the request
-	req, err := http.NewRequestWithContext(timeoutContext, http.MethodPost, "https://api.eloverblik.dk/customerapi/api/meterdata/gettimeseries/"+seriesFrom+"/"+seriesTo+"/Hour", bytes.NewBuffer(bjson))
-	if err != nil {
-		return result, err
-	}
-
-	// add the headers needed
-	req.Header.Add("accept", "application/json")
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Authorization", "Bearer "+eo.RequestToken.Token)
+	// make the http request, retrying if eloverblik is rate limiting or unavailable
+	res, err := eo.Retry.Do(ctx, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.eloverblik.dk/customerapi/api/meterdata/gettimeseries/"+seriesFrom+"/"+seriesTo+"/Hour", bytes.NewBuffer(bjson))
+		if err != nil {
+			return nil, err
+		}
 
-	// make the http request
-	res, err := http.DefaultClient.Do(req)
+		// add the headers needed
+		req.Header.Add("accept", "application/json")
+		req.Header.Add("Content-Type", "application/json")
+		req.Header.Add("Authorization", "Bearer "+eo.RequestToken.Token)
+		return req, nil
+	})
 	if err != nil {
 		return result, err
 	}
+	defer res.Body.Close()
 
 	// check the HTTP status code
 	if res.StatusCode > 299 {
@@ -431,3 +452,62 @@ func (eo *ElOverblik) GetMeterReadings(meteringPoint string, fromDate time.Time,
 	// return the data to the caller
 	return result, nil
 }
+
+// GetCharges makes the "getcharges" request towards eloverblik and returns the tariffs,
+// subscriptions and fees for the meteringpoint
+func (eo *ElOverblik) GetCharges(ctx context.Context, meteringPointId string) (charges EloverblikCharges, err error) {
+
+	// create the json for the body
+	reqBody := EloverblikGetTimeSeriesRequest{}
+	reqBody.MeteringPoints.MeteringPoint = []string{meteringPointId}
+	bjson, err := json.Marshal(&reqBody)
+	if err != nil {
+		return charges, err
+	}
+
+	// make the http request, retrying if eloverblik is rate limiting or unavailable
+	res, err := eo.Retry.Do(ctx, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.eloverblik.dk/customerapi/api/meteringpoints/meteringpoint/getcharges", bytes.NewBuffer(bjson))
+		if err != nil {
+			return nil, err
+		}
+
+		// add the headers needed
+		req.Header.Add("accept", "application/json")
+		req.Header.Add("Content-Type", "application/json")
+		req.Header.Add("Authorization", "Bearer "+eo.RequestToken.Token)
+		return req, nil
+	})
+	if err != nil {
+		return charges, err
+	}
+	defer res.Body.Close()
+
+	// check the HTTP status code
+	if res.StatusCode > 299 {
+		return charges, errors.New("unable to get charges, server responded:" + res.Status)
+	}
+
+	// marshal the json result into struct
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return charges, err
+	}
+
+	// parse the json into the result struct
+	var result EloverblikGetChargesResult
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		return charges, err
+	}
+
+	// we only asked for a single meteringpoint
+	if len(result.Result) == 0 {
+		return charges, errors.New("no charges returned for meteringpoint: " + meteringPointId)
+	}
+	if !result.Result[0].Success {
+		return charges, errors.New("unable to get charges for meteringpoint " + meteringPointId + ": " + result.Result[0].ErrorText)
+	}
+
+	return result.Result[0].Result, nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// dayAheadPricesStart is the first danish date in the DayAheadPrices dataset, it replaced the
+// Elspotprices dataset when the day-ahead market moved to 15 minute prices, Elspotprices isn't
+// updated after the day before
+var dayAheadPricesStart = time.Date(2025, 10, 1, 0, 0, 0, 0, danishTime)
+
+// EnergiDataService gets the spot prices from Energinet's Energi Data Service, the prices are the
+// raw spot prices without any supplier markup
+// URL is the DayAheadPrices dataset, with 15 minute prices since 2025-10-01, which are averaged
+// to hourly prices, and HistoryURL is the Elspotprices dataset, used for the hours before that
+type EnergiDataService struct {
+	URL        string
+	HistoryURL string
+	Retry      RetryPolicy
+}
+
+// EnergiDataServiceResult is the result returned by the DayAheadPrices and Elspotprices datasets,
+// the prices are in DKK/MWh, and are null if they haven't been set
+type EnergiDataServiceResult struct {
+	Total   int `json:"total"`
+	Records []struct {
+		TimeUTC          string   `json:"TimeUTC"` // DayAheadPrices
+		DayAheadPriceDKK *float64 `json:"DayAheadPriceDKK"`
+		HourUTC          string   `json:"HourUTC"` // Elspotprices
+		SpotPriceDKK     *float64 `json:"SpotPriceDKK"`
+		PriceArea        string   `json:"PriceArea"`
+	} `json:"records"`
+}
+
+// Name returns the name of the price provider
+func (eds *EnergiDataService) Name() string {
+	return "Energi Data Service"
+}
+
+// GetPrices gets the spot prices for the sector for the last numberOfDays days, including
+// tomorrow if it has been published, and returns them grouped by danish date
+func (eds *EnergiDataService) GetPrices(ctx context.Context, numberOfDays int, sector string) (res []NorlysPricingResult, err error) {
+	today := time.Now().In(danishTime)
+	start := time.Date(today.Year(), today.Month(), today.Day()-numberOfDays+1, 0, 0, 0, 0, danishTime)
+	end := time.Date(today.Year(), today.Month(), today.Day()+2, 0, 0, 0, 0, danishTime)
+	return eds.GetPricesBetween(ctx, sector, start, end)
+}
+
+// GetPricesBetween gets the hourly spot prices for the sector for the danish dates start <= date < end,
+// and returns them grouped by danish date, the dates before 2025-10-01 are read from HistoryURL
+func (eds *EnergiDataService) GetPricesBetween(ctx context.Context, sector string, start time.Time, end time.Time) (res []NorlysPricingResult, err error) {
+	res = make([]NorlysPricingResult, 0)
+
+	// the hourly prices in DKK/MWh, in the order they are returned
+	hours := make([]time.Time, 0)
+	prices := make(map[time.Time][]float64)
+	add := func(t time.Time, price float64) {
+		hour := t.Truncate(time.Hour)
+		if _, ok := prices[hour]; !ok {
+			hours = append(hours, hour)
+		}
+		prices[hour] = append(prices[hour], price)
+	}
+
+	if start.Before(dayAheadPricesStart) {
+		historyEnd := end
+		if historyEnd.After(dayAheadPricesStart) {
+			historyEnd = dayAheadPricesStart
+		}
+		edsRes, err := eds.fetch(ctx, eds.HistoryURL, "HourUTC", sector, start, historyEnd)
+		if err != nil {
+			return res, err
+		}
+		for _, r := range edsRes.Records {
+			hour, err := time.Parse("2006-01-02T15:04:05", r.HourUTC)
+			if err != nil {
+				return res, errors.New("unable to parse HourUTC: " + err.Error())
+			}
+			if r.SpotPriceDKK != nil {
+				add(hour, *r.SpotPriceDKK)
+			}
+		}
+	}
+
+	if end.After(dayAheadPricesStart) {
+		dayAheadStart := start
+		if dayAheadStart.Before(dayAheadPricesStart) {
+			dayAheadStart = dayAheadPricesStart
+		}
+		edsRes, err := eds.fetch(ctx, eds.URL, "TimeUTC", sector, dayAheadStart, end)
+		if err != nil {
+			return res, err
+		}
+		for _, r := range edsRes.Records {
+			t, err := time.Parse("2006-01-02T15:04:05", r.TimeUTC)
+			if err != nil {
+				return res, errors.New("unable to parse TimeUTC: " + err.Error())
+			}
+			if r.DayAheadPriceDKK != nil {
+				add(t, *r.DayAheadPriceDKK)
+			}
+		}
+	}
+
+	// group the hours by danish date, the hours are stored as the offset from midnight, and the
+	// price of an hour is the average of its 15 minute prices
+	for _, hour := range hours {
+		dk := hour.In(danishTime)
+		priceDate := time.Date(dk.Year(), dk.Month(), dk.Day(), 0, 0, 0, 0, danishTime)
+
+		if len(res) == 0 || !res[len(res)-1].PriceDate.Equal(priceDate) {
+			res = append(res, NorlysPricingResult{PriceDate: priceDate, Sector: sector, Currency: "DKK"})
+		}
+
+		sum := 0.0
+		for _, p := range prices[hour] {
+			sum += p
+		}
+		// the spot price is in DKK/MWh, and we store øre/kWh
+		offset := int(hour.Sub(priceDate) / time.Hour)
+		day := &res[len(res)-1]
+		day.DisplayPrices = append(day.DisplayPrices, NorlysDisplayPrice{Time: strconv.Itoa(offset), Value: sum / float64(len(prices[hour])) / 10})
+	}
+
+	return res, nil
+}
+
+// fetch gets the records of the dataset at datasetURL for the sector for the danish dates
+// start <= date < end, sorted by timeField
+func (eds *EnergiDataService) fetch(ctx context.Context, datasetURL string, timeField string, sector string, start time.Time, end time.Time) (EnergiDataServiceResult, error) {
+	edsRes := EnergiDataServiceResult{}
+
+	// Generate the URL, the datasets use danish time for start and end, and end is exclusive
+	query := url.Values{}
+	query.Set("start", start.In(danishTime).Format("2006-01-02"))
+	query.Set("end", end.In(danishTime).Format("2006-01-02"))
+	query.Set("filter", `{"PriceArea":["`+sector+`"]}`)
+	query.Set("sort", timeField+" asc")
+	query.Set("limit", "0")
+
+	// Make the HTTP call towards the Energi Data Service API, retrying if it's unavailable
+	resp, err := eds.Retry.Do(ctx, func(ctx context.Context) (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, datasetURL+"?"+query.Encode(), nil)
+	})
+	if err != nil {
+		return edsRes, err
+	}
+	defer resp.Body.Close()
+
+	// check response code
+	if resp.StatusCode >= 300 {
+		return edsRes, errors.New("energi data service API returned status:" + resp.Status)
+	}
+
+	// Check the response
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return edsRes, err
+	}
+
+	// parse the json response
+	err = json.Unmarshal(body, &edsRes)
+	return edsRes, err
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newEnergiDataServiceStandIn serves the DayAheadPrices and Elspotprices datasets, with a price
+// for every 15 minutes or every hour of the requested danish dates, the 15 minute prices of an
+// hour are 1000, 1100, 1200 and 1300 DKK/MWh, and the hourly prices are 1150 DKK/MWh
+// The path, start and end of each request is added to requests
+func newEnergiDataServiceStandIn(t *testing.T, requests *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		*requests = append(*requests, r.URL.Path+" "+q.Get("start")+" "+q.Get("end"))
+
+		start, err := time.ParseInLocation("2006-01-02", q.Get("start"), danishTime)
+		if err != nil {
+			t.Errorf("invalid start: %s", q.Get("start"))
+		}
+		end, err := time.ParseInLocation("2006-01-02", q.Get("end"), danishTime)
+		if err != nil {
+			t.Errorf("invalid end: %s", q.Get("end"))
+		}
+		if q.Get("filter") != `{"PriceArea":["DK1"]}` {
+			t.Errorf("unexpected filter: %s", q.Get("filter"))
+		}
+
+		records := make([]map[string]interface{}, 0)
+		switch r.URL.Path {
+		case "/DayAheadPrices":
+			for i, ts := 0, start.UTC(); ts.Before(end.UTC()); i, ts = i+1, ts.Add(15*time.Minute) {
+				records = append(records, map[string]interface{}{
+					"TimeUTC":          ts.Format("2006-01-02T15:04:05"),
+					"PriceArea":        "DK1",
+					"DayAheadPriceDKK": 1000 + float64(i%4)*100,
+				})
+			}
+		case "/Elspotprices":
+			for ts := start.UTC(); ts.Before(end.UTC()); ts = ts.Add(time.Hour) {
+				records = append(records, map[string]interface{}{
+					"HourUTC":      ts.Format("2006-01-02T15:04:05"),
+					"PriceArea":    "DK1",
+					"SpotPriceDKK": 1150.0,
+				})
+			}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"total": len(records), "records": records})
+	}))
+}
+
+func TestEnergiDataServiceGetPricesBetween(t *testing.T) {
+	tests := []struct {
+		name         string
+		start        string
+		end          string
+		wantRequests []string
+		wantHours    map[string]int // hours per danish date
+	}{
+		{
+			name:         "15 minute prices",
+			start:        "2025-11-10",
+			end:          "2025-11-12",
+			wantRequests: []string{"/DayAheadPrices 2025-11-10 2025-11-12"},
+			wantHours:    map[string]int{"2025-11-10": 24, "2025-11-11": 24},
+		},
+		{
+			name:         "15 minute prices on the long DST day",
+			start:        "2025-10-26",
+			end:          "2025-10-27",
+			wantRequests: []string{"/DayAheadPrices 2025-10-26 2025-10-27"},
+			wantHours:    map[string]int{"2025-10-26": 25},
+		},
+		{
+			name:         "hourly prices on the short DST day",
+			start:        "2025-03-30",
+			end:          "2025-03-31",
+			wantRequests: []string{"/Elspotprices 2025-03-30 2025-03-31"},
+			wantHours:    map[string]int{"2025-03-30": 23},
+		},
+		{
+			name:         "across the switch to DayAheadPrices",
+			start:        "2025-09-30",
+			end:          "2025-10-02",
+			wantRequests: []string{"/Elspotprices 2025-09-30 2025-10-01", "/DayAheadPrices 2025-10-01 2025-10-02"},
+			wantHours:    map[string]int{"2025-09-30": 24, "2025-10-01": 24},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := make([]string, 0)
+			server := newEnergiDataServiceStandIn(t, &requests)
+			defer server.Close()
+
+			eds := &EnergiDataService{URL: server.URL + "/DayAheadPrices", HistoryURL: server.URL + "/Elspotprices", Retry: RetryPolicy{MaxAttempts: 1}}
+			start, _ := time.ParseInLocation("2006-01-02", tt.start, danishTime)
+			end, _ := time.ParseInLocation("2006-01-02", tt.end, danishTime)
+			res, err := eds.GetPricesBetween(context.Background(), "DK1", start, end)
+			if err != nil {
+				t.Fatalf("GetPricesBetween returned an error: %v", err)
+			}
+
+			if len(requests) != len(tt.wantRequests) {
+				t.Fatalf("got requests %v, want %v", requests, tt.wantRequests)
+			}
+			for i := range requests {
+				if requests[i] != tt.wantRequests[i] {
+					t.Errorf("got request %q, want %q", requests[i], tt.wantRequests[i])
+				}
+			}
+
+			if len(res) != len(tt.wantHours) {
+				t.Fatalf("got %d days, want %d", len(res), len(tt.wantHours))
+			}
+			for _, day := range res {
+				date := day.PriceDate.In(danishTime).Format("2006-01-02")
+				if day.PriceDate.In(danishTime).Hour() != 0 {
+					t.Errorf("%s: PriceDate %v isn't danish midnight", date, day.PriceDate)
+				}
+				if day.Sector != "DK1" || day.Currency != "DKK" {
+					t.Errorf("%s: got sector %s and currency %s", date, day.Sector, day.Currency)
+				}
+				if len(day.DisplayPrices) != tt.wantHours[date] {
+					t.Errorf("%s: got %d hours, want %d", date, len(day.DisplayPrices), tt.wantHours[date])
+				}
+				for i, p := range day.DisplayPrices {
+					if p.Time != strconv.Itoa(i) {
+						t.Errorf("%s: hour %d has offset %s", date, i, p.Time)
+					}
+					// 1150 DKK/MWh is 115 øre/kWh
+					if p.Value != 115 {
+						t.Errorf("%s: hour %d has price %v, want 115", date, i, p.Value)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEnergiDataServiceGetPricesBetweenError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	eds := &EnergiDataService{URL: server.URL, HistoryURL: server.URL, Retry: RetryPolicy{MaxAttempts: 1}}
+	start := time.Date(2025, 11, 10, 0, 0, 0, 0, danishTime)
+	_, err := eds.GetPricesBetween(context.Background(), "DK1", start, start.AddDate(0, 0, 1))
+	if err == nil {
+		t.Fatal("GetPricesBetween didn't return an error for a 400 response")
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// envPrefix is the prefix of the environment variables that overrides the settings
+const envPrefix = "LIGHTHOUSE_"
+
+// envConfigFile is the environment variable giving the configuration file, it's not a setting
+const envConfigFile = envPrefix + "CONFIG"
+
+// maxEnvListLength is the longest list of tables that can be given by environment variables
+const maxEnvListLength = 100
+
+// hasEnvOverrides checks if any LIGHTHOUSE_* environment variables are set, besides LIGHTHOUSE_CONFIG
+func hasEnvOverrides() bool {
+	for _, env := range os.Environ() {
+		name := strings.SplitN(env, "=", 2)[0]
+		if strings.HasPrefix(name, envPrefix) && name != envConfigFile {
+			return true
+		}
+	}
+	return false
+}
+
+// applyEnvOverrides sets the settings given by LIGHTHOUSE_* environment variables, the name of the
+// variable is the toml path of the field in upper case joined by _, e.g. LIGHTHOUSE_DATABASE_PASSWORD
+// or LIGHTHOUSE_NORLYSAPI_RETRY_MAXATTEMPTS, list of tables are indexed from 0, e.g.
+// LIGHTHOUSE_TENANTS_0_LIGHTHOUSETOKEN
+// Lists of strings are comma separated, and maps are given as key=value,key2=value2
+// Variables that don't match a setting are reported as errors, without their values, as they
+// are likely misspelled secrets
+func applyEnvOverrides(s *Settings) []error {
+	env := make(map[string]string)
+	for _, e := range os.Environ() {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) == 2 && strings.HasPrefix(kv[0], envPrefix) && kv[0] != envConfigFile {
+			env[kv[0]] = kv[1]
+		}
+	}
+	if len(env) == 0 {
+		return nil
+	}
+
+	// the variables are removed from env as they are used
+	errs := applyEnvStruct(reflect.ValueOf(s).Elem(), strings.TrimSuffix(envPrefix, "_"), env)
+	unknown := make([]string, 0, len(env))
+	for name := range env {
+		unknown = append(unknown, name)
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		errs = append(errs, errors.New("environment variable "+name+" doesn't match any setting"))
+	}
+	return errs
+}
+
+// applyEnvStruct sets the fields of the struct v from env, prefix is the name of the struct
+func applyEnvStruct(v reflect.Value, prefix string, env map[string]string) []error {
+	var errs []error
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("toml")
+		if tag == "-" || !t.Field(i).IsExported() {
+			continue
+		}
+		if tag == "" {
+			tag = t.Field(i).Name
+		}
+		errs = append(errs, applyEnvValue(v.Field(i), prefix+"_"+strings.ToUpper(tag), env)...)
+	}
+	return errs
+}
+
+// applyEnvValue sets the field v from the variable called name, or the variables below name if
+// it's a struct or a list of structs
+func applyEnvValue(v reflect.Value, name string, env map[string]string) []error {
+	switch {
+	case v.Kind() == reflect.Struct:
+		return applyEnvStruct(v, name, env)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct:
+		return applyEnvSlice(v, name, env)
+	}
+
+	value, found := env[name]
+	if !found {
+		return nil
+	}
+	delete(env, name)
+	invalid := func(kind string) []error {
+		return []error{errors.New("environment variable " + name + " must be " + kind + ", got: " + value)}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return invalid("a whole number")
+		}
+		v.SetInt(int64(i))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return invalid("true or false")
+		}
+		v.SetBool(b)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return invalid("a number")
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		list := make([]string, 0)
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	case reflect.Map:
+		m := make(map[string]string)
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			kv := strings.SplitN(item, "=", 2)
+			if len(kv) != 2 {
+				return invalid("a list of key=value")
+			}
+			m[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+		v.Set(reflect.ValueOf(m))
+	default:
+		return []error{errors.New("environment variable " + name + " is not supported")}
+	}
+	return nil
+}
+
+// applyEnvSlice sets the list of structs v from the variables called name_0_*, name_1_* and so on,
+// the list is extended to the highest index given, which must follow the tables already in the
+// list without gaps
+func applyEnvSlice(v reflect.Value, name string, env map[string]string) []error {
+	var errs []error
+	maxIndex := -1
+	indexes := make(map[int]bool)
+	for key := range env {
+		if !strings.HasPrefix(key, name+"_") {
+			continue
+		}
+		index := strings.SplitN(strings.TrimPrefix(key, name+"_"), "_", 2)[0]
+		i, err := strconv.Atoi(index)
+		if err != nil || i < 0 || i >= maxEnvListLength || strconv.Itoa(i) != index {
+			errs = append(errs, errors.New("environment variable "+key+" must have an index from 0 to "+strconv.Itoa(maxEnvListLength-1)+" after "+name+"_"))
+			delete(env, key)
+			continue
+		}
+		indexes[i] = true
+		if i > maxIndex {
+			maxIndex = i
+		}
+	}
+	for i := v.Len(); i < maxIndex; i++ {
+		if !indexes[i] {
+			errs = append(errs, errors.New("environment variables "+name+"_"+strconv.Itoa(i)+"_* missing, the list can't have gaps"))
+			for key := range env {
+				if strings.HasPrefix(key, name+"_") {
+					delete(env, key)
+				}
+			}
+			return errs
+		}
+	}
+
+	if maxIndex >= v.Len() {
+		grown := reflect.MakeSlice(v.Type(), maxIndex+1, maxIndex+1)
+		reflect.Copy(grown, v)
+		v.Set(grown)
+	}
+	for i := 0; i <= maxIndex; i++ {
+		errs = append(errs, applyEnvStruct(v.Index(i), name+"_"+strconv.Itoa(i), env)...)
+	}
+	return errs
+}
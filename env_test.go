@@ -0,0 +1,178 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// setEnv sets the environment variables for the test, and removes every other LIGHTHOUSE_* variable
+func setEnv(t *testing.T, env map[string]string) {
+	for _, e := range os.Environ() {
+		name := strings.SplitN(e, "=", 2)[0]
+		if strings.HasPrefix(name, envPrefix) {
+			value := os.Getenv(name)
+			os.Unsetenv(name)
+			t.Cleanup(func() { os.Setenv(name, value) })
+		}
+	}
+	for name, value := range env {
+		t.Setenv(name, value)
+	}
+}
+
+func TestApplyEnvOverrides(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		configured func(s *Settings) // the settings before the overrides, as from the configuration file
+		check      func(s *Settings) bool
+		wantErrs   []string // a part of each of the errors, sorted
+	}{
+		{
+			name:  "string",
+			env:   map[string]string{"LIGHTHOUSE_PRICEPROVIDER": "energidataservice"},
+			check: func(s *Settings) bool { return s.PriceProvider == "energidataservice" },
+		},
+		{
+			name:  "int",
+			env:   map[string]string{"LIGHTHOUSE_APIPORT": "8081"},
+			check: func(s *Settings) bool { return s.APIPort == 8081 },
+		},
+		{
+			name:  "bool",
+			env:   map[string]string{"LIGHTHOUSE_AUTH_ENABLED": "true"},
+			check: func(s *Settings) bool { return s.Auth.Enabled },
+		},
+		{
+			name:  "float",
+			env:   map[string]string{"LIGHTHOUSE_PRICING_VATPERCENT": "12.5"},
+			check: func(s *Settings) bool { return s.Pricing.VATPercent == 12.5 },
+		},
+		{
+			name:  "nested struct",
+			env:   map[string]string{"LIGHTHOUSE_NORLYSAPI_RETRY_MAXATTEMPTS": "7"},
+			check: func(s *Settings) bool { return s.NorlysAPI.Retry.MaxAttempts == 7 },
+		},
+		{
+			name:  "list of strings",
+			env:   map[string]string{"LIGHTHOUSE_NORLYSAPI_SECTORS": "DK1, DK2,"},
+			check: func(s *Settings) bool { return strings.Join(s.NorlysAPI.Sectors, ",") == "DK1,DK2" },
+		},
+		{
+			name: "map",
+			env:  map[string]string{"LIGHTHOUSE_ELOVERBLIK_METERINGPOINTSECTORS": "571=DK1, 572=DK2"},
+			check: func(s *Settings) bool {
+				return len(s.ElOverblik.MeteringPointSectors) == 2 && s.ElOverblik.MeteringPointSectors["572"] == "DK2"
+			},
+		},
+		{
+			name: "secrets are used verbatim",
+			env: map[string]string{
+				"LIGHTHOUSE_DATABASE_PASSWORD": "p=ss,word ",
+				"LIGHTHOUSE_AUTH_JWTSECRET":    "s3cr3t=,;",
+			},
+			check: func(s *Settings) bool { return s.Database.Password == "p=ss,word " && s.Auth.JWTSecret == "s3cr3t=,;" },
+		},
+		{
+			name: "list of tables",
+			env: map[string]string{
+				"LIGHTHOUSE_TENANTS_0_NAME":            "home",
+				"LIGHTHOUSE_TENANTS_0_LIGHTHOUSETOKEN": "token0",
+				"LIGHTHOUSE_TENANTS_1_NAME":            "work",
+				"LIGHTHOUSE_TENANTS_1_USERS":           "alice,bob",
+			},
+			check: func(s *Settings) bool {
+				return len(s.Tenants) == 2 && s.Tenants[0].LighthouseToken == "token0" && s.Tenants[1].Name == "work" && len(s.Tenants[1].Users) == 2
+			},
+		},
+		{
+			name:       "overriding a configured table, and adding one after it",
+			configured: func(s *Settings) { s.Tenants = []Tenant{{Name: "home", LighthouseToken: "token0"}} },
+			env: map[string]string{
+				"LIGHTHOUSE_TENANTS_0_LIGHTHOUSETOKEN": "token1",
+				"LIGHTHOUSE_TENANTS_1_NAME":            "work",
+			},
+			check: func(s *Settings) bool {
+				return len(s.Tenants) == 2 && s.Tenants[0].Name == "home" && s.Tenants[0].LighthouseToken == "token1" && s.Tenants[1].Name == "work"
+			},
+		},
+		{
+			name:     "invalid int",
+			env:      map[string]string{"LIGHTHOUSE_APIPORT": "80a"},
+			wantErrs: []string{"LIGHTHOUSE_APIPORT must be a whole number"},
+		},
+		{
+			name:     "invalid bool",
+			env:      map[string]string{"LIGHTHOUSE_AUTH_ENABLED": "yes please"},
+			wantErrs: []string{"LIGHTHOUSE_AUTH_ENABLED must be true or false"},
+		},
+		{
+			name:     "invalid map",
+			env:      map[string]string{"LIGHTHOUSE_ELOVERBLIK_METERINGPOINTSECTORS": "571"},
+			wantErrs: []string{"LIGHTHOUSE_ELOVERBLIK_METERINGPOINTSECTORS must be a list of key=value"},
+		},
+		{
+			name:     "unknown variable, without its value",
+			env:      map[string]string{"LIGHTHOUSE_DATABASE_PASWORD": "secret-value"},
+			wantErrs: []string{"LIGHTHOUSE_DATABASE_PASWORD doesn't match any setting"},
+		},
+		{
+			name:     "unknown field of a table",
+			env:      map[string]string{"LIGHTHOUSE_TENANTS_0_NAM": "home"},
+			wantErrs: []string{"LIGHTHOUSE_TENANTS_0_NAM doesn't match any setting"},
+		},
+		{
+			name:     "index that isn't a number",
+			env:      map[string]string{"LIGHTHOUSE_TENANTS_FIRST_NAME": "home"},
+			wantErrs: []string{"LIGHTHOUSE_TENANTS_FIRST_NAME must have an index"},
+		},
+		{
+			name:     "index that is too large",
+			env:      map[string]string{"LIGHTHOUSE_TENANTS_1000000000_NAME": "home"},
+			wantErrs: []string{"LIGHTHOUSE_TENANTS_1000000000_NAME must have an index"},
+		},
+		{
+			name:     "index with a leading zero",
+			env:      map[string]string{"LIGHTHOUSE_TENANTS_01_NAME": "home"},
+			wantErrs: []string{"LIGHTHOUSE_TENANTS_01_NAME must have an index"},
+		},
+		{
+			name:     "gap in the list",
+			env:      map[string]string{"LIGHTHOUSE_TENANTS_0_NAME": "home", "LIGHTHOUSE_TENANTS_2_NAME": "work"},
+			wantErrs: []string{"LIGHTHOUSE_TENANTS_1_* missing"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, tt.env)
+			s := &Settings{}
+			if tt.configured != nil {
+				tt.configured(s)
+			}
+
+			errs := applyEnvOverrides(s)
+			got := make([]string, 0, len(errs))
+			for _, err := range errs {
+				got = append(got, err.Error())
+				if strings.Contains(err.Error(), "secret-value") {
+					t.Errorf("the error contains the value of the variable: %s", err.Error())
+				}
+			}
+			sort.Strings(got)
+			if len(got) != len(tt.wantErrs) {
+				t.Fatalf("got errors %v, want %v", got, tt.wantErrs)
+			}
+			for i := range got {
+				if !strings.Contains(got[i], tt.wantErrs[i]) {
+					t.Errorf("got error %q, want %q", got[i], tt.wantErrs[i])
+				}
+			}
+			if tt.check != nil && !tt.check(s) {
+				t.Errorf("the settings weren't overridden: %+v", s)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ExportUsageEntry is a single hourly reading written by `lighthouse export`
+type ExportUsageEntry struct {
+	Time     time.Time `json:"time"`
+	Quantity float64   `json:"quantity"`
+	Unit     string    `json:"unit"`
+	Quality  string    `json:"quality"`
+}
+
+// ExportPriceEntry is a single hourly spot price written by `lighthouse export`, in øre/kWh
+type ExportPriceEntry struct {
+	Time  time.Time `json:"time"`
+	Price float64   `json:"price"`
+}
+
+// runExportCommand runs `lighthouse export`, which writes the stored usage of a meteringpoint or
+// the spot prices of a sector to stdout, or the file given by --output
+func runExportCommand(args []string) int {
+	flags := newFlagSet("export")
+	data := flags.String("data", "usage", "what to export: usage or prices")
+	meteringPointId := flags.String("meteringPointId", "", "the meteringpoint to export the usage of")
+	sector := flags.String("sector", "", "the sector to export the prices of (default: the first configured sector)")
+	from := flags.String("from", "", "start of the period, as 2006-01-02 (default: NumberOfDaysForMeteringData days ago)")
+	to := flags.String("to", "", "end of the period, as 2006-01-02, exclusive (default: tomorrow)")
+	format := flags.String("format", "csv", "the output format: csv or json")
+	output := flags.String("output", "", "the file to write to (default: stdout)")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if *data != "usage" && *data != "prices" {
+		fmt.Fprintln(os.Stderr, "--data must be usage or prices")
+		return exitUsage
+	}
+	if *data == "usage" && *meteringPointId == "" {
+		fmt.Fprintln(os.Stderr, "--meteringPointId is required when exporting usage")
+		return exitUsage
+	}
+	if *format != "csv" && *format != "json" {
+		fmt.Fprintln(os.Stderr, "--format must be csv or json")
+		return exitUsage
+	}
+
+	settings, db, code := loadSettingsAndStore()
+	if code != exitOK {
+		return code
+	}
+	defer db.Close()
+
+	now := time.Now().In(danishTime)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, danishTime)
+	fromTime, err := parseDateFlag("from", *from, today.AddDate(0, 0, -settings.NumberOfDaysForMeteringData))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return exitUsage
+	}
+	toTime, err := parseDateFlag("to", *to, today.AddDate(0, 0, 1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return exitUsage
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			log.Println("Error creating output file:", err.Error())
+			return exitFailure
+		}
+		defer file.Close()
+		w = file
+	}
+
+	switch *data {
+	case "usage":
+		err = exportUsage(w, db, *meteringPointId, fromTime, toTime, *format)
+	case "prices":
+		if *sector == "" {
+			*sector = settings.NorlysAPI.Sectors[0]
+		}
+		err = exportPrices(w, db, *sector, fromTime, toTime, *format)
+	}
+	if err != nil {
+		log.Println("Error exporting", *data+":", err.Error())
+		return exitFailure
+	}
+	return exitOK
+}
+
+// exportUsage writes the readings of the meteringpoint, where from <= hour < to
+func exportUsage(w io.Writer, db Store, meteringPointId string, from time.Time, to time.Time, format string) error {
+	readings, err := db.GetMeteringTimeSeries(meteringPointId, from, to)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]ExportUsageEntry, 0, len(readings))
+	for _, r := range readings {
+		entries = append(entries, ExportUsageEntry{Time: r.Hour, Quantity: r.Quantity, Unit: r.MeasurementUnit, Quality: r.Quality})
+	}
+	if format == "json" {
+		return writeExportJSON(w, entries)
+	}
+
+	rows := [][]string{{"time", "quantity", "unit", "quality"}}
+	for _, e := range entries {
+		rows = append(rows, []string{e.Time.Format(time.RFC3339), strconv.FormatFloat(e.Quantity, 'f', -1, 64), e.Unit, e.Quality})
+	}
+	return csv.NewWriter(w).WriteAll(rows)
+}
+
+// exportPrices writes the spot prices of the sector, where from <= hour < to
+func exportPrices(w io.Writer, db Store, sector string, from time.Time, to time.Time, format string) error {
+	prices, err := db.GetSpotPrices(sector, from, to)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]ExportPriceEntry, 0, len(prices))
+	for _, p := range prices {
+		entries = append(entries, ExportPriceEntry{Time: p.Hour, Price: p.Price})
+	}
+	if format == "json" {
+		return writeExportJSON(w, entries)
+	}
+
+	rows := [][]string{{"time", "price"}}
+	for _, e := range entries {
+		rows = append(rows, []string{e.Time.Format(time.RFC3339), strconv.FormatFloat(e.Price, 'f', -1, 64)})
+	}
+	return csv.NewWriter(w).WriteAll(rows)
+}
+
+// writeExportJSON writes the entries as an indented JSON array
+func writeExportJSON(w io.Writer, entries interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
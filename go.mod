@@ -2,18 +2,21 @@ module lighthouse
 
 go 1.19
 
-require github.com/go-sql-driver/mysql v1.6.0
+require (
+	github.com/BurntSushi/toml v1.2.0
+	github.com/brianvoe/sjwt v0.5.1
+	github.com/go-sql-driver/mysql v1.6.0
+	github.com/labstack/echo/v4 v4.9.1
+	github.com/mattn/go-sqlite3 v1.14.16
+	golang.org/x/crypto v0.0.0-20210817164053-32db794688a5
+)
 
 require (
-	github.com/BurntSushi/toml v1.2.0 // indirect
-	github.com/brianvoe/sjwt v0.5.1 // indirect
-	github.com/labstack/echo/v4 v4.9.1 // indirect
 	github.com/labstack/gommon v0.4.0 // indirect
 	github.com/mattn/go-colorable v0.1.11 // indirect
 	github.com/mattn/go-isatty v0.0.14 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/valyala/fasttemplate v1.2.1 // indirect
-	golang.org/x/crypto v0.0.0-20210817164053-32db794688a5 // indirect
 	golang.org/x/net v0.0.0-20211015210444-4f30a5c0130f // indirect
 	golang.org/x/sys v0.0.0-20211103235746-7861aae1554b // indirect
 	golang.org/x/text v0.3.7 // indirect
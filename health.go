@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// startTime is used to give the collectors time to make their first fetch, before they are reported as stale
+var startTime = time.Now()
+
+// eloverblikAccounts holds the eloverblik account of each tenant, so the health checks can
+// report the token expiry
+var eloverblikAccounts = &ElOverblikAccounts{accounts: make(map[string]*ElOverblik)}
+
+// ElOverblikAccounts is a registry of the eloverblik accounts used by the collectors
+type ElOverblikAccounts struct {
+	lock     sync.RWMutex
+	accounts map[string]*ElOverblik
+}
+
+// Register adds the account of the tenant to the registry
+func (ea *ElOverblikAccounts) Register(tenant string, eo *ElOverblik) {
+	ea.lock.Lock()
+	defer ea.lock.Unlock()
+	ea.accounts[tenant] = eo
+}
+
+// Get returns the account of the tenant, if it's registered
+func (ea *ElOverblikAccounts) Get(tenant string) (*ElOverblik, bool) {
+	ea.lock.RLock()
+	defer ea.lock.RUnlock()
+	eo, ok := ea.accounts[tenant]
+	return eo, ok
+}
+
+// Health check statuses, pending is used until a collector has had time to make its first fetch
+const (
+	healthOK      = "ok"
+	healthPending = "pending"
+	healthStale   = "stale"
+	healthError   = "error"
+)
+
+// HealthCheck is the result of a single check
+type HealthCheck struct {
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`
+	Message     string     `json:"message,omitempty"`
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	Expires     *time.Time `json:"expires,omitempty"`
+
+	tenant string // the tenant checked, if any
+}
+
+// HealthResponse is the result returned when calling /healthz and /readyz, the checks are
+// left out for requests that aren't authenticated, as they name the tenants and contain the
+// errors returned by eloverblik
+type HealthResponse struct {
+	Status string        `json:"status"`
+	Checks []HealthCheck `json:"checks,omitempty"`
+}
+
+// HandleGETHealthz reports the database, collector and token status, and responds with
+// 503 if the database is unreachable or any of the collectors or tokens are stale
+func (a *API) HandleGETHealthz(c echo.Context) error {
+	checks := a.healthChecks(c.Request().Context())
+
+	res := HealthResponse{Status: healthOK, Checks: a.visibleHealthChecks(c, checks)}
+	for _, check := range checks {
+		if check.Status == healthStale || check.Status == healthError {
+			res.Status = check.Status
+			return c.JSON(http.StatusServiceUnavailable, res)
+		}
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// HandleGETReadyz reports the same checks as /healthz, but only responds with 503 if the
+// database is unreachable or there are no prices to serve yet
+func (a *API) HandleGETReadyz(c echo.Context) error {
+	checks := a.healthChecks(c.Request().Context())
+
+	res := HealthResponse{Status: healthOK, Checks: a.visibleHealthChecks(c, checks)}
+	for _, check := range checks {
+		if check.Name == "database" && check.Status != healthOK {
+			res.Status = check.Status
+			return c.JSON(http.StatusServiceUnavailable, res)
+		}
+	}
+	// when the prices aren't updated in the background, they are collected by another process
+	for _, sector := range a.Settings.NorlysAPI.Sectors {
+		stats, _ := collectorMetrics.Get("prices", sector)
+		if !a.Settings.NorlysAPI.DisableUpdates && stats.LastSuccess.IsZero() {
+			res.Status = healthPending
+			return c.JSON(http.StatusServiceUnavailable, res)
+		}
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// visibleHealthChecks returns the checks the logged in user can see, which is none if the request
+// isn't authenticated, and only the eloverblik checks of their own tenants for the users
+func (a *API) visibleHealthChecks(c echo.Context, checks []HealthCheck) []HealthCheck {
+	if _, ok := c.Get("user").(User); !ok && a.Settings.Auth.Enabled {
+		return nil
+	}
+	allowed := a.allowedTenants(c)
+	if allowed == nil {
+		return checks
+	}
+
+	res := make([]HealthCheck, 0, len(checks))
+	for _, check := range checks {
+		visible := check.tenant == ""
+		for _, t := range allowed {
+			visible = visible || check.tenant == t
+		}
+		if visible {
+			res = append(res, check)
+		}
+	}
+	return res
+}
+
+// healthChecks runs every check
+func (a *API) healthChecks(ctx context.Context) []HealthCheck {
+	checks := make([]HealthCheck, 0)
+
+	// make sure the database is reachable
+	pingContext, cancelFunc := context.WithTimeout(ctx, 5*time.Second)
+	defer cancelFunc()
+	db := HealthCheck{Name: "database", Status: healthOK}
+	if err := a.DB.Ping(pingContext); err != nil {
+		db.Status = healthError
+		db.Message = err.Error()
+	}
+	checks = append(checks, db)
+
+	// the collectors are stale if they haven't succeeded for two of their periods, the disabled
+	// collectors aren't checked
+	if !a.Settings.NorlysAPI.DisableUpdates {
+		// with smart polling, the prices are only updated once a day when they are published on time
+		schedule, _ := a.Settings.PricesSchedule()
+		period := schedule.Period()
+		if !a.Settings.NorlysAPI.DisableSmartPolling && period < 24*time.Hour {
+			period = 24 * time.Hour
+		}
+		for _, sector := range a.Settings.NorlysAPI.Sectors {
+			checks = append(checks, collectorHealth("prices", sector, 2*period))
+		}
+	}
+	if a.Settings.ElOverblik.FetchDataFromElOverblik {
+		schedule, _ := a.Settings.EloverblikSchedule()
+		for _, tenant := range a.Settings.TenantList() {
+			tenantChecks := append([]HealthCheck{collectorHealth("eloverblik", tenant.Name, 2*schedule.Period())}, tokenHealth(tenant.Name, schedule.Period())...)
+			for _, check := range tenantChecks {
+				check.tenant = tenant.Name
+				checks = append(checks, check)
+			}
+		}
+	}
+
+	return checks
+}
+
+// collectorHealth checks when the collector last succeeded for the target
+func collectorHealth(collector string, target string, staleAfter time.Duration) HealthCheck {
+	check := HealthCheck{Name: collector + "/" + target, Status: healthOK}
+	stats, _ := collectorMetrics.Get(collector, target)
+	if stats.LastError.After(stats.LastSuccess) {
+		check.Message = stats.LastErrorText
+	}
+
+	if stats.LastSuccess.IsZero() {
+		check.Status = healthPending
+		if time.Since(startTime) > staleAfter {
+			check.Status = healthStale
+		}
+		return check
+	}
+
+	lastSuccess := stats.LastSuccess
+	check.LastSuccess = &lastSuccess
+	if time.Since(lastSuccess) > staleAfter {
+		check.Status = healthStale
+	}
+	return check
+}
+
+// tokenHealth checks the expiry of the application and request token of the tenant, the request
+// token is renewed by the collector, so it's only stale if it has been expired for a full interval
+func tokenHealth(tenant string, interval time.Duration) []HealthCheck {
+	application := HealthCheck{Name: "eloverblik/" + tenant + "/applicationToken", Status: healthOK}
+	request := HealthCheck{Name: "eloverblik/" + tenant + "/requestToken", Status: healthOK}
+
+	eo, ok := eloverblikAccounts.Get(tenant)
+	if !ok {
+		application.Status = healthPending
+		request.Status = healthPending
+		return []HealthCheck{application, request}
+	}
+	applicationExpire, requestExpire := eo.TokenExpiry()
+
+	if applicationExpire.IsZero() {
+		application.Status = healthError
+		application.Message = "the application token is invalid"
+	} else {
+		application.Expires = &applicationExpire
+		if applicationExpire.Before(time.Now()) {
+			application.Status = healthStale
+			application.Message = "the application token has expired, please update it on eloverblik.dk website"
+		} else if time.Until(applicationExpire) < 14*24*time.Hour {
+			application.Message = "the application token expires soon"
+		}
+	}
+
+	if requestExpire.IsZero() {
+		request.Status = healthPending
+		if time.Since(startTime) > interval {
+			request.Status = healthStale
+		}
+	} else {
+		request.Expires = &requestExpire
+		if time.Since(requestExpire) > interval {
+			request.Status = healthStale
+		}
+	}
+
+	return []HealthCheck{application, request}
+}
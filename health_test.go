@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthDetailsRequireAuthentication(t *testing.T) {
+	settings, _, e := newTestServer(t)
+	settings.NorlysAPI.DisableUpdates = true
+	settings.ElOverblik.FetchDataFromElOverblik = true
+	settings.ElOverblik.FetchDataInterval = 3600
+	settings.Tenants = []Tenant{
+		{Name: "healthhome", LighthouseToken: "token", Users: []string{"bob"}},
+		{Name: "healthwork", LighthouseToken: "token"},
+	}
+	collectorMetrics.Record("eloverblik", "healthhome", time.Second, errors.New("meteringpoint 571313100000000001 not found"))
+	collectorMetrics.Record("eloverblik", "healthwork", time.Second, errors.New("meteringpoint 571313100000000002 not found"))
+
+	admin := login(t, e, "admin", "admin-password")
+	bob := login(t, e, "bob", "bob-password")
+
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		wantChecks []string // the names of the checks returned
+	}{
+		{name: "healthz without credentials", path: "/healthz"},
+		{name: "healthz with invalid credentials", path: "/healthz", token: "lh_invalid"},
+		{name: "readyz without credentials", path: "/readyz"},
+		{name: "healthz as a user", path: "/healthz", token: bob, wantChecks: []string{"database",
+			"eloverblik/healthhome", "eloverblik/healthhome/applicationToken", "eloverblik/healthhome/requestToken"}},
+		{name: "healthz as the admin", path: "/healthz", token: admin, wantChecks: []string{"database",
+			"eloverblik/healthhome", "eloverblik/healthhome/applicationToken", "eloverblik/healthhome/requestToken",
+			"eloverblik/healthwork", "eloverblik/healthwork/applicationToken", "eloverblik/healthwork/requestToken"}},
+		{name: "readyz as a user", path: "/readyz", token: bob, wantChecks: []string{"database",
+			"eloverblik/healthhome", "eloverblik/healthhome/applicationToken", "eloverblik/healthhome/requestToken"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := request(e, http.MethodGet, tt.path, tt.token, "")
+			if rec.Code != http.StatusOK && rec.Code != http.StatusServiceUnavailable {
+				t.Fatalf("got %d: %s", rec.Code, rec.Body.String())
+			}
+			res := HealthResponse{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+			if res.Status == "" {
+				t.Error("the overall status is missing")
+			}
+
+			names := make([]string, 0)
+			for _, check := range res.Checks {
+				names = append(names, check.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.wantChecks, ",") {
+				t.Errorf("got checks %v, want %v", names, tt.wantChecks)
+			}
+			if tt.wantChecks == nil && (strings.Contains(rec.Body.String(), "health") || strings.Contains(rec.Body.String(), "5713131")) {
+				t.Errorf("the response contains details: %s", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestHealthDetailsWithoutAuth(t *testing.T) {
+	settings, db := newTestStore(t)
+	settings.NorlysAPI.DisableUpdates = true
+	e, err := newServer(settings, db)
+	if err != nil {
+		t.Fatalf("newServer returned an error: %v", err)
+	}
+
+	rec := request(e, http.MethodGet, "/healthz", "", "")
+	res := HealthResponse{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if rec.Code != http.StatusOK || res.Status != healthOK || len(res.Checks) != 1 || res.Checks[0].Name != "database" {
+		t.Errorf("got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -1,8 +1,486 @@
 package main
 
-import "github.com/labstack/echo/v4"
+import (
+	"net/http"
+	"time"
 
-func HandleGETUsage(c echo.Context) error {
+	"github.com/labstack/echo/v4"
+)
 
-	return c.String(200, "Not Implemented")
+// API contains the handlers for the HTTP API, and the dependencies they need
+type API struct {
+	Settings *Settings
+	DB       Store
+}
+
+// allowedTenants returns the tenants the logged in user can see, or nil if the user can
+// see every tenant, which is the case for the admin user, or if authentication is disabled
+func (a *API) allowedTenants(c echo.Context) []string {
+	if !a.Settings.Auth.Enabled {
+		return nil
+	}
+	user, ok := c.Get("user").(User)
+	if !ok {
+		return []string{}
+	}
+	if user.Username == a.Settings.Auth.AdminUsername {
+		return nil
+	}
+	return a.Settings.TenantsForUser(user.Username)
+}
+
+// checkMeteringPoint returns a HTTP error if the meteringpoint doesn't exist, or the logged
+// in user isn't allowed to see it, both are reported as unknown meteringpoints
+func (a *API) checkMeteringPoint(c echo.Context, meteringPointId string) error {
+	exists, err := a.DB.MeteringPointExists(meteringPointId)
+	if err != nil {
+		c.Logger().Error("error looking up meteringpoint: ", err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "unable to look up meteringpoint")
+	}
+	if !exists {
+		return echo.NewHTTPError(http.StatusNotFound, "unknown meteringpoint: "+meteringPointId)
+	}
+
+	allowed := a.allowedTenants(c)
+	if allowed == nil {
+		return nil
+	}
+	tenants, err := a.DB.GetMeteringPointTenants(meteringPointId)
+	if err != nil {
+		c.Logger().Error("error looking up meteringpoint tenants: ", err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "unable to look up meteringpoint")
+	}
+	for _, t := range tenants {
+		for _, at := range allowed {
+			if t == at {
+				return nil
+			}
+		}
+	}
+	return echo.NewHTTPError(http.StatusNotFound, "unknown meteringpoint: "+meteringPointId)
+}
+
+// HandleGETMeteringPoints returns the stored meteringpoints the logged in user can see
+func (a *API) HandleGETMeteringPoints(c echo.Context) error {
+	mps, err := a.DB.GetMeteringPoints(a.allowedTenants(c))
+	if err != nil {
+		c.Logger().Error("error getting meteringpoints: ", err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "unable to get meteringpoints")
+	}
+	return c.JSON(http.StatusOK, mps)
+}
+
+// UsageResponse is the result returned when calling GET /usage
+type UsageResponse struct {
+	MeteringPointId string       `json:"meteringPointId"`
+	Resolution      string       `json:"resolution"`
+	From            time.Time    `json:"from"`
+	To              time.Time    `json:"to"`
+	Unit            string       `json:"unit"`
+	Total           float64      `json:"total"`
+	Usage           []UsageEntry `json:"usage"`
+}
+
+// UsageEntry is the consumption for a single hour, day or month
+type UsageEntry struct {
+	Time     time.Time `json:"time"`
+	Quantity float64   `json:"quantity"`
+}
+
+// HandleGETUsage returns the consumption for a meteringpoint
+//
+// query parameters:
+//
+//	meteringPointId: the meteringpoint to get consumption for (required)
+//	from: start of the period, as 2006-01-02 or RFC3339 (default: NumberOfDaysForMeteringData days ago)
+//	to: end of the period, as 2006-01-02 or RFC3339 (default: now)
+//	resolution: hour, day or month (default: hour)
+func (a *API) HandleGETUsage(c echo.Context) error {
+
+	// validate the query parameters
+	meteringPointId := c.QueryParam("meteringPointId")
+	if meteringPointId == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "meteringPointId is required")
+	}
+
+	resolution := c.QueryParam("resolution")
+	if resolution == "" {
+		resolution = "hour"
+	}
+	if resolution != "hour" && resolution != "day" && resolution != "month" {
+		return echo.NewHTTPError(http.StatusBadRequest, "resolution must be one of hour, day or month")
+	}
+
+	from, to, err := parseTimeRange(c, time.Duration(a.Settings.NumberOfDaysForMeteringData*24)*time.Hour)
+	if err != nil {
+		return err
+	}
+
+	// check that we know the meteringpoint, and the user is allowed to see it
+	if err := a.checkMeteringPoint(c, meteringPointId); err != nil {
+		return err
+	}
+
+	// get the hourly data, and aggregate it to the requested resolution
+	series, err := a.DB.GetMeteringTimeSeries(meteringPointId, from, to)
+	if err != nil {
+		c.Logger().Error("error getting metering time-series: ", err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "unable to get consumption data")
+	}
+
+	res := UsageResponse{
+		MeteringPointId: meteringPointId,
+		Resolution:      resolution,
+		From:            from,
+		To:              to,
+		Usage:           make([]UsageEntry, 0),
+	}
+	for _, e := range series {
+		res.Unit = e.MeasurementUnit
+		res.Total += e.Quantity
+
+		t := truncateToResolution(e.Hour, resolution)
+		if len(res.Usage) > 0 && res.Usage[len(res.Usage)-1].Time.Equal(t) {
+			res.Usage[len(res.Usage)-1].Quantity += e.Quantity
+			continue
+		}
+		res.Usage = append(res.Usage, UsageEntry{Time: t, Quantity: e.Quantity})
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// CostResponse is the result returned when calling GET /cost, all costs are in DKK
+type CostResponse struct {
+	MeteringPointId string          `json:"meteringPointId"`
+	Sector          string          `json:"sector"`
+	Currency        string          `json:"currency"`
+	From            time.Time       `json:"from"`
+	To              time.Time       `json:"to"`
+	Quantity        float64         `json:"quantity"`
+	Total           float64         `json:"total"`
+	MissingPrices   int             `json:"missingPrices"`
+	Hours           []HourCostEntry `json:"hours"`
+	Days            []DayCostEntry  `json:"days"`
+}
+
+// HourCostEntry is the consumption, price and cost for a single hour
+// Price is the all-in price including tariffs, taxes and VAT, and SpotPrice is the price
+// without, both in øre/kWh, and they are nil if no spot price is known for the hour
+type HourCostEntry struct {
+	Time      time.Time `json:"time"`
+	Quantity  float64   `json:"quantity"`
+	SpotPrice *float64  `json:"spotPrice"`
+	Price     *float64  `json:"price"`
+	Cost      float64   `json:"cost"`
+}
+
+// DayCostEntry is the consumption and cost for a single day
+type DayCostEntry struct {
+	Time     time.Time `json:"time"`
+	Quantity float64   `json:"quantity"`
+	Cost     float64   `json:"cost"`
+}
+
+// HandleGETCost returns the cost of the consumption for a meteringpoint, calculated
+// from the hourly consumption and the all-in price for each hour
+//
+// query parameters:
+//
+//	meteringPointId: the meteringpoint to get the cost for (required)
+//	from: start of the period, as 2006-01-02 or RFC3339 (default: NumberOfDaysForMeteringData days ago)
+//	to: end of the period, as 2006-01-02 or RFC3339 (default: now)
+func (a *API) HandleGETCost(c echo.Context) error {
+
+	// validate the query parameters
+	meteringPointId := c.QueryParam("meteringPointId")
+	if meteringPointId == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "meteringPointId is required")
+	}
+
+	from, to, err := parseTimeRange(c, time.Duration(a.Settings.NumberOfDaysForMeteringData*24)*time.Hour)
+	if err != nil {
+		return err
+	}
+
+	// check that we know the meteringpoint, and the user is allowed to see it
+	if err := a.checkMeteringPoint(c, meteringPointId); err != nil {
+		return err
+	}
+
+	sector, err := a.DB.GetMeteringPointSector(meteringPointId)
+	if err != nil {
+		c.Logger().Error("error getting meteringpoint sector: ", err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "unable to look up meteringpoint")
+	}
+	if sector == "" {
+		sector = a.Settings.NorlysAPI.Sectors[0]
+	}
+
+	hours, err := a.DB.GetHourlyCost(meteringPointId, sector, from, to)
+	if err != nil {
+		c.Logger().Error("error getting hourly cost: ", err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "unable to get cost data")
+	}
+
+	tariffs, err := a.DB.GetTariffs(meteringPointId, from, to)
+	if err != nil {
+		c.Logger().Error("error getting tariffs: ", err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "unable to get tariffs")
+	}
+	pc := NewPriceCalculator(a.Settings, tariffs)
+
+	res := CostResponse{
+		MeteringPointId: meteringPointId,
+		Sector:          sector,
+		Currency:        "DKK",
+		From:            from,
+		To:              to,
+		Hours:           make([]HourCostEntry, 0, len(hours)),
+		Days:            make([]DayCostEntry, 0),
+	}
+	for _, h := range hours {
+		entry := HourCostEntry{Time: h.Hour, Quantity: h.Quantity}
+		if h.Price.Valid {
+			// prices are in øre/kWh, the cost is in DKK
+			spot := h.Price.Float64
+			price := pc.Price(h.Hour, spot).Total
+			entry.SpotPrice = &spot
+			entry.Price = &price
+			entry.Cost = h.Quantity * price / 100
+		} else {
+			res.MissingPrices++
+		}
+		res.Hours = append(res.Hours, entry)
+		res.Quantity += entry.Quantity
+		res.Total += entry.Cost
+
+		day := truncateToResolution(h.Hour, "day")
+		if len(res.Days) > 0 && res.Days[len(res.Days)-1].Time.Equal(day) {
+			res.Days[len(res.Days)-1].Quantity += entry.Quantity
+			res.Days[len(res.Days)-1].Cost += entry.Cost
+			continue
+		}
+		res.Days = append(res.Days, DayCostEntry{Time: day, Quantity: entry.Quantity, Cost: entry.Cost})
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// PricesResponse is the result returned when calling GET /prices, all prices are in øre/kWh
+type PricesResponse struct {
+	Sector          string      `json:"sector"`
+	MeteringPointId string      `json:"meteringPointId,omitempty"`
+	From            time.Time   `json:"from"`
+	To              time.Time   `json:"to"`
+	Prices          []HourPrice `json:"prices"`
+}
+
+// HandleGETPrices returns the all-in hourly prices, with the spot price, grid tariffs,
+// taxes and VAT for each hour
+//
+// query parameters:
+//
+//	sector: the price sector, DK1 or DK2 (default: the first configured sector)
+//	meteringPointId: the meteringpoint to use the grid tariffs from (default: no grid tariffs)
+//	from: start of the period, as 2006-01-02 or RFC3339 (default: start of today)
+//	to: end of the period, as 2006-01-02 or RFC3339 (default: end of tomorrow)
+func (a *API) HandleGETPrices(c echo.Context) error {
+	sector := c.QueryParam("sector")
+	if sector == "" {
+		sector = a.Settings.NorlysAPI.Sectors[0]
+	}
+	if !validSector(sector) {
+		return echo.NewHTTPError(http.StatusBadRequest, "sector must be DK1 or DK2")
+	}
+
+	// default to today and tomorrow, as that is what is published
+	from := truncateToResolution(time.Now(), "day")
+	to := from.AddDate(0, 0, 2)
+	var err error
+	if c.QueryParam("from") != "" || c.QueryParam("to") != "" {
+		from, to, err = parseTimeRange(c, 48*time.Hour)
+		if err != nil {
+			return err
+		}
+	}
+
+	res := PricesResponse{
+		Sector: sector,
+		From:   from,
+		To:     to,
+		Prices: make([]HourPrice, 0),
+	}
+
+	tariffs := make([]StoredTariff, 0)
+	if mpId := c.QueryParam("meteringPointId"); mpId != "" {
+		if err := a.checkMeteringPoint(c, mpId); err != nil {
+			return err
+		}
+
+		tariffs, err = a.DB.GetTariffs(mpId, from, to)
+		if err != nil {
+			c.Logger().Error("error getting tariffs: ", err.Error())
+			return echo.NewHTTPError(http.StatusInternalServerError, "unable to get tariffs")
+		}
+		res.MeteringPointId = mpId
+	}
+
+	spotPrices, err := a.DB.GetSpotPrices(sector, from, to)
+	if err != nil {
+		c.Logger().Error("error getting spot prices: ", err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "unable to get prices")
+	}
+
+	pc := NewPriceCalculator(a.Settings, tariffs)
+	for _, sp := range spotPrices {
+		res.Prices = append(res.Prices, pc.Price(sp.Hour, sp.Price))
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// PlanResponse is the result returned when calling GET /plan, all prices are in øre/kWh
+type PlanResponse struct {
+	Sector          string     `json:"sector"`
+	MeteringPointId string     `json:"meteringPointId,omitempty"`
+	Duration        string     `json:"duration"`
+	From            time.Time  `json:"from"`
+	To              time.Time  `json:"to"`
+	Window          *PlanHours `json:"window"`
+	CheapestHours   PlanHours  `json:"cheapestHours"`
+}
+
+// HandleGETPlan finds the cheapest hours to run an appliance in the upcoming hours that
+// has prices, both as one contiguous window and as the cheapest individual hours
+//
+// query parameters:
+//
+//	duration: how long the appliance needs to run, in whole hours, e.g. 3h (required)
+//	before: the danish time the appliance must be done by, as 15:04 (default: no limit)
+//	sector: the price sector, DK1 or DK2 (default: the first configured sector)
+//	meteringPointId: the meteringpoint to use the grid tariffs from (default: no grid tariffs)
+func (a *API) HandleGETPlan(c echo.Context) error {
+
+	// validate the query parameters
+	duration, err := time.ParseDuration(c.QueryParam("duration"))
+	if err != nil || duration < time.Hour || duration%time.Hour != 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "duration must be a whole number of hours, e.g. 3h")
+	}
+	hours := int(duration / time.Hour)
+
+	sector := c.QueryParam("sector")
+	if sector == "" {
+		sector = a.Settings.NorlysAPI.Sectors[0]
+	}
+	if !validSector(sector) {
+		return echo.NewHTTPError(http.StatusBadRequest, "sector must be DK1 or DK2")
+	}
+
+	// the horizon starts with the current hour, and ends at the before time or when we run out of prices
+	now := time.Now()
+	from := now.Truncate(time.Hour)
+	to := from.AddDate(0, 0, 3)
+	if before := c.QueryParam("before"); before != "" {
+		b, err := time.Parse("15:04", before)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "before must be a time of day, e.g. 07:00")
+		}
+		to = nextOccurrence(now, b.Hour(), b.Minute()).Truncate(time.Hour)
+	}
+
+	res := PlanResponse{
+		Sector:   sector,
+		Duration: c.QueryParam("duration"),
+		From:     from,
+		To:       to,
+	}
+
+	tariffs := make([]StoredTariff, 0)
+	if mpId := c.QueryParam("meteringPointId"); mpId != "" {
+		if err := a.checkMeteringPoint(c, mpId); err != nil {
+			return err
+		}
+
+		tariffs, err = a.DB.GetTariffs(mpId, from, to)
+		if err != nil {
+			c.Logger().Error("error getting tariffs: ", err.Error())
+			return echo.NewHTTPError(http.StatusInternalServerError, "unable to get tariffs")
+		}
+		res.MeteringPointId = mpId
+	}
+
+	spotPrices, err := a.DB.GetSpotPrices(sector, from, to)
+	if err != nil {
+		c.Logger().Error("error getting spot prices: ", err.Error())
+		return echo.NewHTTPError(http.StatusInternalServerError, "unable to get prices")
+	}
+
+	pc := NewPriceCalculator(a.Settings, tariffs)
+	prices := make([]HourPrice, 0, len(spotPrices))
+	for _, sp := range spotPrices {
+		prices = append(prices, pc.Price(sp.Hour, sp.Price))
+	}
+
+	cheapest, ok := CheapestHours(prices, hours)
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "not enough prices in the horizon to plan "+c.QueryParam("duration"))
+	}
+	res.CheapestHours = cheapest
+
+	// there might not be a contiguous window, if some hours are missing prices
+	if window, ok := CheapestWindow(prices, hours); ok {
+		res.Window = &window
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+// parseTimeRange reads the from and to query parameters, if from is missing it defaults
+// to defaultPeriod before to, and if to is missing it defaults to now
+func parseTimeRange(c echo.Context, defaultPeriod time.Duration) (from time.Time, to time.Time, err error) {
+	to = time.Now()
+	if c.QueryParam("to") != "" {
+		to, err = parseQueryTime(c.QueryParam("to"))
+		if err != nil {
+			return from, to, echo.NewHTTPError(http.StatusBadRequest, "invalid to: "+err.Error())
+		}
+	}
+
+	from = to.Add(-defaultPeriod)
+	if c.QueryParam("from") != "" {
+		from, err = parseQueryTime(c.QueryParam("from"))
+		if err != nil {
+			return from, to, echo.NewHTTPError(http.StatusBadRequest, "invalid from: "+err.Error())
+		}
+	}
+
+	if !from.Before(to) {
+		return from, to, echo.NewHTTPError(http.StatusBadRequest, "from must be before to")
+	}
+
+	return from, to, nil
+}
+
+// parseQueryTime parses a time from a query parameter, either as a danish date or as RFC3339
+func parseQueryTime(s string) (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01-02", s, danishTime)
+	if err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// truncateToResolution returns the start of the hour, day or month t is in, using danish time, so
+// the days and months doesn't depend on the timezone of the host
+func truncateToResolution(t time.Time, resolution string) time.Time {
+	t = t.In(danishTime)
+	switch resolution {
+	case "day":
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, danishTime)
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, danishTime)
+	default:
+		return t.Truncate(time.Hour)
+	}
 }
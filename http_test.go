@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// newTestAPI returns a server without authentication, with a DK1 meteringpoint that has a reading
+// of 1 kWh for each hour of the long DST day 2025-10-26, and a spot price of 80 øre/kWh for all of
+// that day's hours but the last five
+func newTestAPI(t *testing.T) (*Settings, Store, *echo.Echo) {
+	settings, db := newTestStore(t)
+	settings.NorlysAPI.Sectors = []string{"DK1"}
+	settings.Pricing.VATPercent = 25
+	e, err := newServer(settings, db)
+	if err != nil {
+		t.Fatalf("newServer returned an error: %v", err)
+	}
+
+	mps := []EloverblikMeteringPoint{{MeteringPointId: "571313100000000001", Sector: "DK1"}}
+	if err := db.SaveMeteringPoints("home", &mps); err != nil {
+		t.Fatalf("unable to save meteringpoint: %v", err)
+	}
+	day := time.Date(2025, 10, 26, 0, 0, 0, 0, danishTime)
+	quantities := make([]float64, 25)
+	for i := range quantities {
+		quantities[i] = 1
+	}
+	saveTestReadings(t, db, "571313100000000001", day, quantities...)
+	prices := make([]float64, 20)
+	for i := range prices {
+		prices[i] = 80
+	}
+	saveTestPrices(t, db, "DK1", day, prices...)
+	return settings, db, e
+}
+
+// getJSON makes a GET request towards the server, and decodes the response into res
+func getJSON(t *testing.T, e *echo.Echo, path string, res interface{}) {
+	rec := request(e, http.MethodGet, path, "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s returned %d: %s", path, rec.Code, rec.Body.String())
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), res); err != nil {
+		t.Fatalf("GET %s returned invalid JSON: %v", path, err)
+	}
+}
+
+func TestHandleGETInvalidRequests(t *testing.T) {
+	_, _, e := newTestAPI(t)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{name: "usage without meteringpoint", path: "/usage?from=2025-10-26&to=2025-10-27", wantStatus: http.StatusBadRequest},
+		{name: "usage with malformed from", path: "/usage?meteringPointId=571313100000000001&from=26-10-2025&to=2025-10-27", wantStatus: http.StatusBadRequest},
+		{name: "usage with malformed to", path: "/usage?meteringPointId=571313100000000001&from=2025-10-26&to=tomorrow", wantStatus: http.StatusBadRequest},
+		{name: "usage with from after to", path: "/usage?meteringPointId=571313100000000001&from=2025-10-27&to=2025-10-26", wantStatus: http.StatusBadRequest},
+		{name: "usage with from equal to to", path: "/usage?meteringPointId=571313100000000001&from=2025-10-26&to=2025-10-26", wantStatus: http.StatusBadRequest},
+		{name: "usage with invalid resolution", path: "/usage?meteringPointId=571313100000000001&resolution=week", wantStatus: http.StatusBadRequest},
+		{name: "usage for unknown meteringpoint", path: "/usage?meteringPointId=571313100000000002&from=2025-10-26&to=2025-10-27", wantStatus: http.StatusNotFound},
+		{name: "cost without meteringpoint", path: "/cost?from=2025-10-26&to=2025-10-27", wantStatus: http.StatusBadRequest},
+		{name: "cost with malformed from", path: "/cost?meteringPointId=571313100000000001&from=2025-10-26T00:00&to=2025-10-27", wantStatus: http.StatusBadRequest},
+		{name: "cost with from after to", path: "/cost?meteringPointId=571313100000000001&from=2025-10-27&to=2025-10-26", wantStatus: http.StatusBadRequest},
+		{name: "cost for unknown meteringpoint", path: "/cost?meteringPointId=571313100000000002&from=2025-10-26&to=2025-10-27", wantStatus: http.StatusNotFound},
+		{name: "prices with malformed to", path: "/prices?from=2025-10-26&to=2025-13-01", wantStatus: http.StatusBadRequest},
+		{name: "prices with from equal to to", path: "/prices?from=2025-10-26T00:00:00Z&to=2025-10-26T00:00:00Z", wantStatus: http.StatusBadRequest},
+		{name: "prices for unknown sector", path: "/prices?sector=DK3&from=2025-10-26&to=2025-10-27", wantStatus: http.StatusBadRequest},
+		{name: "prices for unknown meteringpoint", path: "/prices?meteringPointId=571313100000000002&from=2025-10-26&to=2025-10-27", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := request(e, http.MethodGet, tt.path, "", "")
+			if rec.Code != tt.wantStatus {
+				t.Errorf("GET %s returned %d, want %d: %s", tt.path, rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleGETUsage(t *testing.T) {
+	_, _, e := newTestAPI(t)
+
+	// the long DST day has 25 hours, and the danish days before and after are empty
+	hourly := UsageResponse{}
+	getJSON(t, e, "/usage?meteringPointId=571313100000000001&from=2025-10-26&to=2025-10-27", &hourly)
+	if len(hourly.Usage) != 25 || hourly.Total != 25 || hourly.Unit != "KWH" {
+		t.Fatalf("hourly: got %d hours, total %v %s, want 25 hours, total 25 KWH", len(hourly.Usage), hourly.Total, hourly.Unit)
+	}
+	if !hourly.Usage[0].Time.Equal(time.Date(2025, 10, 25, 22, 0, 0, 0, time.UTC)) || !hourly.Usage[24].Time.Equal(time.Date(2025, 10, 26, 22, 0, 0, 0, time.UTC)) {
+		t.Errorf("hourly: got hours from %v to %v", hourly.Usage[0].Time, hourly.Usage[24].Time)
+	}
+
+	daily := UsageResponse{}
+	getJSON(t, e, "/usage?meteringPointId=571313100000000001&from=2025-10-25&to=2025-10-28&resolution=day", &daily)
+	if len(daily.Usage) != 1 || daily.Usage[0].Quantity != 25 {
+		t.Fatalf("daily: got %+v, want one day of 25 KWH", daily.Usage)
+	}
+	if !daily.Usage[0].Time.Equal(time.Date(2025, 10, 26, 0, 0, 0, 0, danishTime)) {
+		t.Errorf("daily: got day %v, want danish midnight of 2025-10-26", daily.Usage[0].Time)
+	}
+}
+
+func TestHandleGETCost(t *testing.T) {
+	_, _, e := newTestAPI(t)
+
+	// the 20 hours with a price cost 80 øre/kWh, 100 with VAT, and the last five has no price
+	res := CostResponse{}
+	getJSON(t, e, "/cost?meteringPointId=571313100000000001&from=2025-10-26&to=2025-10-27", &res)
+	if len(res.Hours) != 25 || res.MissingPrices != 5 {
+		t.Fatalf("got %d hours with %d missing prices, want 25 hours with 5 missing prices", len(res.Hours), res.MissingPrices)
+	}
+	if res.Quantity != 25 || res.Total != 20 {
+		t.Errorf("got quantity %v and total %v, want 25 and 20", res.Quantity, res.Total)
+	}
+	for i, h := range res.Hours {
+		if i < 20 && (h.SpotPrice == nil || *h.SpotPrice != 80 || h.Price == nil || *h.Price != 100 || h.Cost != 1) {
+			t.Errorf("hour %d: got %+v, want spot price 80, price 100 and cost 1", i, h)
+		}
+		if i >= 20 && (h.SpotPrice != nil || h.Price != nil || h.Cost != 0) {
+			t.Errorf("hour %d: got %+v, want no price and no cost", i, h)
+		}
+	}
+	if len(res.Days) != 1 || res.Days[0].Quantity != 25 || res.Days[0].Cost != 20 {
+		t.Errorf("got days %+v, want one day of 25 kWh costing 20", res.Days)
+	}
+}
+
+func TestHandleGETPrices(t *testing.T) {
+	_, db, e := newTestAPI(t)
+
+	// the short DST day has 23 hours
+	shortDay := time.Date(2025, 3, 30, 0, 0, 0, 0, danishTime)
+	prices := make([]float64, 23)
+	for i := range prices {
+		prices[i] = 40
+	}
+	saveTestPrices(t, db, "DK1", shortDay, prices...)
+
+	tests := []struct {
+		name      string
+		path      string
+		wantHours int
+	}{
+		{name: "long DST day, with the stored hours", path: "/prices?from=2025-10-26&to=2025-10-27", wantHours: 20},
+		{name: "short DST day", path: "/prices?from=2025-03-30&to=2025-03-31", wantHours: 23},
+		{name: "with the tariffs of a meteringpoint", path: "/prices?meteringPointId=571313100000000001&from=2025-10-26&to=2025-10-27", wantHours: 20},
+		{name: "without prices", path: "/prices?sector=DK2&from=2025-10-26&to=2025-10-27", wantHours: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := PricesResponse{}
+			getJSON(t, e, tt.path, &res)
+			if len(res.Prices) != tt.wantHours {
+				t.Fatalf("got %d hours, want %d", len(res.Prices), tt.wantHours)
+			}
+			for i, p := range res.Prices {
+				if p.Total != p.Spot*1.25 {
+					t.Errorf("hour %d: got total %v for spot price %v, want spot price with VAT", i, p.Total, p.Spot)
+				}
+				if i > 0 && p.Hour.Sub(res.Prices[i-1].Hour) != time.Hour {
+					t.Errorf("hour %d: %v isn't the hour after %v", i, p.Hour, res.Prices[i-1].Hour)
+				}
+			}
+		})
+	}
+}
@@ -1,22 +1,101 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
-	"os"
 	"time"
 )
 
-// GetAndSaveNorlysPrices fetches data from norlys and saves it to database
-func GetAndSaveNorlysPrices(settings *Settings, db *Database) {
-	n := NorlysAPI{}
-	for {
-		// get the current norlys prices, and update the database
-		log.Println("Getting prices from Norlys...")
-		prices, err := n.GetPrices(settings.NumberOfDaysForPrices, settings)
+// GetAndSaveNorlysPrices fetches prices from the configured price provider, which
+// defaults to norlys, and saves them to database, until ctx is cancelled
+// Unless smart polling is disabled, it polls in the publication window until tomorrow's prices
+// are available, otherwise it uses the prices schedule
+func GetAndSaveNorlysPrices(ctx context.Context, settings *Settings, db Store) {
+	provider := NewPriceProvider(settings)
+	notifier := NewNotifier(settings, db)
+	retry := settings.NorlysAPI.Retry
+	if settings.PriceProvider == "energidataservice" {
+		retry = settings.EnergiDataService.Retry
+	}
+	schedule, _ := settings.PricesSchedule()
+	collector := Collector{Name: "prices", Schedule: schedule, Retry: retry}
+
+	var poller *PricePoller
+	if !settings.NorlysAPI.DisableSmartPolling {
+		poller = NewPricePoller(settings)
+		collector.Next = poller.Next
+		log.Println("Running the prices collector every", poller.PollInterval, "during", settings.NorlysAPI.PublicationWindow,
+			"until tomorrow's prices are available, and", schedule.String(), "if they are late")
+	}
+
+	undelivered := false
+	collector.Run = func(ctx context.Context) error {
+		var err error
+		undelivered, err = collectPricesAndNotify(ctx, settings, db, provider, poller, notifier, undelivered, time.Now())
+		return err
+	}
+	RunCollector(ctx, collector)
+}
+
+// collectPricesAndNotify collects the prices, and evaluates the notification rules when this collection
+// stored the last of tomorrow's prices, or when the last evaluation had notifications that weren't delivered
+// Whether the prices are new is decided from the stored prices, so a restart doesn't notify again
+// It returns if there still are notifications that weren't delivered
+func collectPricesAndNotify(ctx context.Context, settings *Settings, db Store, provider PriceProvider,
+	poller *PricePoller, notifier *Notifier, undelivered bool, now time.Time) (bool, error) {
+	_, storedBefore, err := tomorrowsPricesStored(db, settings.NorlysAPI.Sectors, now)
+	if err != nil {
+		log.Println("Error checking for tomorrow's prices:", err.Error())
+		storedBefore = true
+	}
+
+	err = CollectPrices(ctx, settings, db, provider)
+	if err != nil {
+		return undelivered, err
+	}
+
+	// let's check if tomorrow's prices has been published, the poller keeps the result for Next
+	check := tomorrowsPricesStored
+	if poller != nil {
+		check = poller.Check
+	}
+	day, stored, err := check(db, settings.NorlysAPI.Sectors, now)
+	if err != nil {
+		log.Println("Error checking for tomorrow's prices:", err.Error())
+		return undelivered, nil
+	}
+	published := stored && !storedBefore
+	if published {
+		if poller != nil {
+			log.Println("Prices for", day.Format("2006-01-02"), "are available, next update at",
+				poller.Next(now).In(danishTime).Format("2006-01-02 15:04"))
+		} else {
+			log.Println("Prices for", day.Format("2006-01-02"), "are available")
+		}
+	}
+
+	// the new prices may trigger notifications, newPrices rules are sent when tomorrow's
+	// prices are available
+	if published || undelivered {
+		return !notifier.Evaluate(ctx, now), nil
+	}
+	return false, nil
+}
+
+// CollectPrices fetches the prices for each of the sectors once, and saves them to database,
+// the sectors that fail doesn't stop the rest, and the last error is returned
+func CollectPrices(ctx context.Context, settings *Settings, db Store, provider PriceProvider) error {
+	var lastErr error
+	for _, sector := range settings.NorlysAPI.Sectors {
+		// get the current prices, and update the database
+		log.Println("Getting prices from", provider.Name(), "for sector", sector)
+		start := time.Now()
+		prices, err := provider.GetPrices(ctx, settings.NumberOfDaysForPrices, sector)
+		collectorMetrics.Record("prices", sector, time.Since(start), err)
 		if err != nil {
-			// we got an error while trying to get the prices from Norlys, we'll wait 60 seconds and try again.
-			log.Println("Error getting prices from norlys:", err.Error())
-			time.Sleep(60 * time.Second)
+			log.Println("Error getting prices from", provider.Name()+":", err.Error())
+			lastErr = err
 			continue
 		}
 
@@ -25,79 +104,110 @@ func GetAndSaveNorlysPrices(settings *Settings, db *Database) {
 			err = db.SaveNorlysPricingResult(&pd)
 			if err != nil {
 				log.Println("Error saving the prices to db:", err.Error())
+				lastErr = err
 			}
 		}
+	}
+	return lastErr
+}
 
-		// wait until the configured time has passed before updating the DB again
-		time.Sleep(time.Duration(settings.NorlysAPI.UpdatePricesInterval) * time.Second)
+// GetAndSaveEloverblikData fetches all data from the eloverblik account of the tenant an saves it to database,
+// on the eloverblik schedule until ctx is cancelled
+func GetAndSaveEloverblikData(ctx context.Context, settings *Settings, db Store, tenant Tenant) {
+	eo := &ElOverblik{Tenant: tenant.Name, Retry: settings.ElOverblik.Retry, TokenDir: settings.DataDir()}
+	eloverblikAccounts.Register(tenant.Name, eo)
+
+	// set the application token, if it's invalid there is nothing we can do for this tenant
+	err := eo.SetApplicationToken(tenant.LighthouseToken)
+	if err != nil {
+		log.Println("ERROR: tenant", tenant.Name+":", err.Error())
+		return
 	}
+	schedule, _ := settings.EloverblikSchedule()
+
+	RunCollector(ctx, Collector{Name: "eloverblik for tenant " + tenant.Name, Schedule: schedule, Retry: eo.Retry, Run: func(ctx context.Context) error {
+		return CollectEloverblik(ctx, settings, db, eo)
+	}})
 }
 
-// GetAndSaveEloverblikData fetches all data from eloverblik an saves it to database
-func GetAndSaveEloverblikData(settings *Settings, db *Database) {
-	eo := ElOverblik{}
+// CollectEloverblik fetches the meteringpoints, charges and readings from the eloverblik account
+// once, and saves them to database, the application token of eo must be set
+// The meteringpoints that fail doesn't stop the rest, and the last error is returned
+func CollectEloverblik(ctx context.Context, settings *Settings, db Store, eo *ElOverblik) error {
+	start := time.Now()
+	err := collectEloverblik(ctx, settings, db, eo)
+	collectorMetrics.Record("eloverblik", eo.Tenant, time.Since(start), err)
+	return err
+}
 
-	// set the application token
-	err := eo.SetApplicationToken(settings.ElOverblik.LighthouseToken)
+// collectEloverblik does the work for CollectEloverblik
+func collectEloverblik(ctx context.Context, settings *Settings, db Store, eo *ElOverblik) error {
+	// let's make a token request to get a request token
+	log.Println("Getting request token from Eloverblik for tenant", eo.Tenant)
+	err := eo.GetRequestToken(ctx, false, settings.SaveRequestTokenToDisk)
 	if err != nil {
-		log.Println("ERROR:", err.Error())
-		os.Exit(1)
+		log.Println("Error getting request token from eloverblik:", err.Error())
+		return err
 	}
 
-	for {
-		// let's make a token request to get a request token
-		log.Println("Getting request token from Eloverblik")
-		err := eo.GetRequestToken(false, settings.SaveRequestTokenToDisk)
-		if err != nil {
-			log.Println("Error getting request token from eloverblik:", err.Error())
-			time.Sleep(60 * time.Second)
-			continue
-		}
+	// let's get the meteringspoints associated to the account
+	log.Println("Getting meteringpoints from Eloverblik")
+	mps, err := eo.GetMeteringPoints(ctx)
+	if err != nil {
+		log.Println("Error getting meteringpoints from eloverblik:", err.Error())
+		return err
+	}
 
-		// let's get the meteringspoints associated to the account
-		log.Println("Getting meteringpoints from Eloverblik")
-		mps, err := eo.GetMeteringPoints()
-		if err != nil {
-			log.Println("Error getting meteringpoints from eloverblik:", err.Error())
-			time.Sleep(60 * time.Second)
-			continue
-		}
+	// let's find the price sector of each meteringpoint, and save the meteringpoints to database
+	for i := range mps {
+		mps[i].Sector = SectorForMeteringPoint(settings, mps[i])
+	}
+	eo.MeteringPoints = mps
+	log.Println("Saving Eloverblik data to database")
+	err = db.SaveMeteringPoints(eo.Tenant, &mps)
+	if err != nil {
+		log.Println("Error saving meteringpoints to database:", err.Error())
+		return err
+	}
 
-		// let's save the meteringpoints to database
-		eo.MeteringPoints = mps
-		log.Println("Saving Eloverblik data to database")
-		err = db.SaveMeteringPoints(&mps)
-		if err != nil {
-			log.Println("Error saving meteringpoints to database:", err.Error())
-			time.Sleep(60 * time.Second)
-			continue
+	var lastErr error
+	for _, mp := range mps {
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
 
-		for _, mp := range mps {
-			// let's get the latest time-series data associated to this meteringpoint
-			fromDate := time.Now().Add(-time.Hour * time.Duration(settings.NumberOfDaysForMeteringData*24))
-			toDate := time.Now().Add(-time.Hour * 1)
-			meterReadings, err := eo.GetMeterReadings(mp.MeteringPointId, fromDate, toDate)
+		// let's get the tariffs, subscriptions and fees for this meteringpoint
+		charges, err := eo.GetCharges(ctx, mp.MeteringPointId)
+		if err != nil {
+			log.Println("Error getting charges from eloverblik:", err.Error())
+		} else {
+			err = db.SaveCharges(charges)
 			if err != nil {
-				log.Println("Error getting meter time-series data:", err.Error())
-				time.Sleep(60 * time.Second)
-				continue
+				log.Println("Error saving charges to db:", err.Error())
 			}
+		}
 
-			if len(meterReadings.Result) > 0 {
-				// let's save the data to database
-				err = db.SaveMeteringTimeSeries(meterReadings)
-				if err != nil {
-					log.Println("Error saving meter time-series data to db:", err.Error())
-					time.Sleep(60 * time.Second)
-					continue
-				}
-			}
+		// let's get the time-series data since the last sync of this meteringpoint, and save it to database
+		err = SyncMeteringPoint(ctx, settings, db, eo, mp.MeteringPointId)
+		if err != nil {
+			log.Println("Error syncing meter time-series data:", err.Error())
+			lastErr = errors.New("meteringpoint " + mp.MeteringPointId + ": " + err.Error())
 		}
+	}
+
+	log.Println("Done fetching data from Eloverblik for tenant", eo.Tenant)
+	return lastErr
+}
 
-		log.Println("Done fetching data from Eloverblik")
+// sleepContext waits for the duration, and returns false if ctx is cancelled before it has passed
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
 
-		// wait until the configured time has passed before updating the DB again
-		time.Sleep(time.Duration(settings.NorlysAPI.UpdatePricesInterval) * time.Second)
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
 	}
 }
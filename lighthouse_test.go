@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// priceProviderStandIn returns 24 prices for each of the days, starting with today
+type priceProviderStandIn struct {
+	days int
+}
+
+func (p *priceProviderStandIn) Name() string {
+	return "stand-in"
+}
+
+func (p *priceProviderStandIn) GetPrices(ctx context.Context, numberOfDays int, sector string) ([]NorlysPricingResult, error) {
+	today := time.Date(2025, 11, 10, 0, 0, 0, 0, danishTime)
+	res := make([]NorlysPricingResult, 0)
+	for d := 0; d < p.days; d++ {
+		pd := NorlysPricingResult{PriceDate: today.AddDate(0, 0, d), Sector: sector, Currency: priceCurrency}
+		for i := 0; i < 24; i++ {
+			pd.DisplayPrices = append(pd.DisplayPrices, NorlysDisplayPrice{Time: strconv.Itoa(i), Value: 80})
+		}
+		res = append(res, pd)
+	}
+	return res, nil
+}
+
+func TestCollectPricesAndNotify(t *testing.T) {
+	settings, db := newTestStore(t)
+	settings.NorlysAPI.Sectors = []string{"DK1"}
+	settings.NumberOfDaysForPrices = 2
+
+	// the first push fails, so it must be retried by the next collection
+	gotify := &pushStandIn{statuses: []int{http.StatusInternalServerError}}
+	gotifyServer := httptest.NewServer(gotify.handle(t, true))
+	defer gotifyServer.Close()
+	settings.Notifications.Targets = []NotificationTarget{{Name: "desktop", Type: "gotify", URL: gotifyServer.URL, Token: "gotify-token"}}
+	settings.Notifications.Rules = []NotificationRule{{Name: "new", Type: "newPrices", Targets: []string{"desktop"}}}
+
+	start, end, _ := parsePublicationWindow("12:45-15:00")
+	newPoller := func() *PricePoller {
+		return &PricePoller{WindowStart: start, WindowEnd: end, PollInterval: 5 * time.Minute}
+	}
+	provider := &priceProviderStandIn{}
+	poller := newPoller()
+	notifier := NewNotifier(settings, db)
+	undelivered := false
+	now := time.Date(2025, 11, 10, 13, 0, 0, 0, danishTime)
+
+	tests := []struct {
+		name            string
+		days            int
+		restart         bool
+		wantUndelivered bool
+		wantPushed      int
+		wantNext        time.Time
+	}{
+		{name: "only today's prices", days: 1, wantPushed: 0, wantNext: now.Add(5 * time.Minute)},
+		{name: "tomorrow's prices, the push fails", days: 2, wantUndelivered: true, wantPushed: 0, wantNext: now.AddDate(0, 0, 1).Add(-15 * time.Minute)},
+		{name: "the push is retried", days: 2, wantPushed: 1, wantNext: now.AddDate(0, 0, 1).Add(-15 * time.Minute)},
+		{name: "nothing new", days: 2, wantPushed: 1, wantNext: now.AddDate(0, 0, 1).Add(-15 * time.Minute)},
+		{name: "a restart doesn't notify again", days: 2, restart: true, wantPushed: 1, wantNext: now.AddDate(0, 0, 1).Add(-15 * time.Minute)},
+	}
+
+	for _, tt := range tests {
+		provider.days = tt.days
+		if tt.restart {
+			poller = newPoller()
+			notifier = NewNotifier(settings, db)
+			undelivered = false
+		}
+
+		var err error
+		undelivered, err = collectPricesAndNotify(context.Background(), settings, db, provider, poller, notifier, undelivered, now)
+		if err != nil {
+			t.Fatalf("%s: collectPricesAndNotify returned an error: %v", tt.name, err)
+		}
+		if undelivered != tt.wantUndelivered {
+			t.Errorf("%s: got undelivered %v, want %v", tt.name, undelivered, tt.wantUndelivered)
+		}
+		if got := len(gotify.delivered()); got != tt.wantPushed {
+			t.Errorf("%s: got %d notifications, want %d", tt.name, got, tt.wantPushed)
+		}
+		if got := poller.Next(now); !got.Equal(tt.wantNext) {
+			t.Errorf("%s: Next = %v, want %v", tt.name, got, tt.wantNext)
+		}
+	}
+}
@@ -1,45 +1,170 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"github.com/labstack/echo/v4"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
+	"sync"
+	"time"
 )
 
 func main() {
-	// Read configuration file
-	settings := Settings{}
-	err := settings.ReadConfigurationFile()
-	if err != nil {
-		fmt.Println(err.Error())
-		os.Exit(1)
+	os.Exit(runCommand(os.Args[1:]))
+}
+
+// runServeCommand runs `lighthouse serve`, which collects data in the background and serves the
+// API until SIGINT or SIGTERM is received
+func runServeCommand(args []string) int {
+	flags := newFlagSet("serve")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
 	}
 
-	// connect to database
-	db := Database{}
-	err = db.ConnectToDatabase(&settings)
+	// Read configuration file, and connect to database
+	settings, db, code := loadSettingsAndStore()
+	if code != exitOK {
+		return code
+	}
+
+	// the root context is cancelled on SIGINT or SIGTERM, which stops the collectors
+	ctx, stop := signalContext()
+	defer stop()
+
+	e, err := newServer(settings, db)
 	if err != nil {
-		log.Println("error connecting to db:", err.Error())
-		os.Exit()
+		log.Println("error creating admin user:", err.Error())
+		db.Close()
+		return exitDatabase
 	}
+	server := &http.Server{Addr: ":" + strconv.Itoa(settings.APIPort)}
+	var redirect *http.Server
+	if settings.TLS.Enabled {
+		certs, err := NewCertReloader(settings)
+		if err != nil {
+			log.Println("error loading TLS certificate:", err.Error())
+			db.Close()
+			return exitConfig
+		}
+		certs.ReloadOnSIGHUP()
+		server.TLSConfig = certs.TLSConfig()
+
+		if settings.TLS.RedirectHTTP {
+			redirect = NewHTTPSRedirectServer(settings.TLS.HTTPPort, settings.APIPort)
+			go func() {
+				log.Println("Redirecting HTTP requests on port", settings.TLS.HTTPPort, "to HTTPS")
+				if err := redirect.ListenAndServe(); err != http.ErrServerClosed {
+					log.Println("error starting HTTP redirect:", err.Error())
+				}
+			}()
+		}
+		log.Println("Listening for HTTPS requests on port", settings.APIPort)
+	} else {
+		log.Println("Listening for HTTP requests on port", settings.APIPort)
+	}
+
+	var collectors sync.WaitGroup
 
 	// Manage updating and saving of Norlys prices
-	go GetAndSaveNorlysPrices(&settings, &db)
+	if settings.NorlysAPI.DisableUpdates {
+		log.Println("Price updates are disabled, the prices must be collected by lighthouse collect")
+	} else {
+		collectors.Add(1)
+		go func() {
+			defer collectors.Done()
+			GetAndSaveNorlysPrices(ctx, settings, db)
+		}()
+	}
 
-	// Manage updating and saving of Eloverblik Data
-	go GetAndSaveEloverblikData(&settings, &db)
+	// Manage updating and saving of Eloverblik Data, for each of the tenants
+	if !settings.ElOverblik.FetchDataFromElOverblik {
+		log.Println("Fetching data from eloverblik is disabled, set FetchDataFromElOverblik to enable it")
+	} else if len(settings.TenantList()) == 0 {
+		log.Println("WARNING: no eloverblik token configured, consumption data won't be fetched")
+	} else {
+		for _, tenant := range settings.TenantList() {
+			collectors.Add(1)
+			go func(tenant Tenant) {
+				defer collectors.Done()
+				GetAndSaveEloverblikData(ctx, settings, db, tenant)
+			}(tenant)
+		}
+	}
 
-	// init the echo library
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- e.StartServer(server)
+	}()
+
+	// wait for SIGINT or SIGTERM, then stop accepting requests and wait for the collectors
+	// to finish what they are writing to the database, before closing it
+	select {
+	case err := <-serverErr:
+		log.Println("error starting the HTTP server:", err.Error())
+		code = exitFailure
+	case <-ctx.Done():
+	}
+	stop()
+	log.Println("Shutting down")
+
+	shutdownContext, cancelFunc := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancelFunc()
+	if err := server.Shutdown(shutdownContext); err != nil {
+		log.Println("error shutting down the HTTP server:", err.Error())
+	}
+	if redirect != nil {
+		if err := redirect.Shutdown(shutdownContext); err != nil {
+			log.Println("error shutting down the HTTP redirect:", err.Error())
+		}
+	}
+
+	collectors.Wait()
+	if err := db.Close(); err != nil {
+		log.Println("error closing the database:", err.Error())
+	}
+	log.Println("Stopped")
+	return code
+}
+
+// newServer creates the echo server with the routes of the API, which requires authentication
+// if it's enabled, the admin user is created if it doesn't exist
+func newServer(settings *Settings, db Store) (*echo.Echo, error) {
 	e := echo.New()
 	e.HideBanner = true
 	e.HidePort = true
 
-	e.GET("/usage", HandleGETUsage)
-	log.Println("Listening for HTTPS requests on port 4001")
-	if err := e.Start(":" + strconv.Itoa(settings.APIPort)); err != http.ErrServerClosed {
-		log.Fatal(err)
+	// require authentication for the API, if enabled
+	if settings.Auth.Enabled {
+		auth := Auth{Settings: settings, DB: db}
+		err := auth.EnsureAdminUser()
+		if err != nil {
+			return nil, err
+		}
+		e.Use(auth.Middleware)
+		e.POST("/login", auth.HandlePOSTLogin)
+		e.POST("/logout", auth.HandlePOSTLogout)
+		e.GET("/apikeys", auth.HandleGETAPIKeys)
+		e.POST("/apikeys", auth.HandlePOSTAPIKeys)
+		e.DELETE("/apikeys/:id", auth.HandleDELETEAPIKey)
+		e.GET("/users", auth.HandleGETUsers)
+		e.POST("/users", auth.HandlePOSTUsers)
+		e.DELETE("/users/:username", auth.HandleDELETEUser)
+		e.PUT("/users/:username/password", auth.HandlePUTUserPassword)
+	} else {
+		log.Println("WARNING: authentication is disabled, the API is available to everyone who can reach it")
 	}
+
+	api := API{Settings: settings, DB: db}
+	e.GET("/usage", api.HandleGETUsage)
+	e.GET("/cost", api.HandleGETCost)
+	e.GET("/prices", api.HandleGETPrices)
+	e.GET("/plan", api.HandleGETPlan)
+	e.GET("/meteringpoints", api.HandleGETMeteringPoints)
+	e.GET("/metrics", api.HandleGETMetrics)
+	e.GET("/healthz", api.HandleGETHealthz)
+	e.GET("/readyz", api.HandleGETReadyz)
+	RegisterWebUI(e)
+	return e, nil
 }
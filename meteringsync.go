@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+)
+
+// DateRange is a period, where From <= time < To
+type DateRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// splitDateRange splits the period from-to into ranges of at most days days
+func splitDateRange(from time.Time, to time.Time, days int) []DateRange {
+	res := make([]DateRange, 0)
+	for start := from; start.Before(to); {
+		end := start.AddDate(0, 0, days)
+		if end.After(to) {
+			end = to
+		}
+		res = append(res, DateRange{From: start, To: end})
+		start = end
+	}
+	return res
+}
+
+// SyncMeteringPoint fetches the readings for the meteringpoint since its high-water mark, going
+// back SyncOverlapHours to pick up late corrections, or NumberOfDaysForMeteringData days back
+// if the meteringpoint hasn't been synced before
+func SyncMeteringPoint(ctx context.Context, settings *Settings, db Store, eo *ElOverblik, meteringPointId string) error {
+	to := time.Now().Add(-time.Hour)
+	from := to.AddDate(0, 0, -settings.NumberOfDaysForMeteringData)
+
+	lastHour, found, err := db.GetMeteringHighWaterMark(meteringPointId)
+	if err != nil {
+		return err
+	}
+	if found {
+		from = lastHour.Add(-time.Duration(settings.ElOverblik.SyncOverlapHours) * time.Hour)
+	}
+
+	return SaveMeteringReadings(ctx, settings, db, eo, meteringPointId, from, to)
+}
+
+// SaveMeteringReadings fetches the readings for the meteringpoint where from <= hour < to, in
+// chunks of at most MaxDaysPerRequest days, and saves them to database
+// The high-water mark is moved forward after each chunk, so the progress isn't lost if a
+// later chunk fails
+func SaveMeteringReadings(ctx context.Context, settings *Settings, db Store, eo *ElOverblik, meteringPointId string, from time.Time, to time.Time) error {
+	lastHour, _, err := db.GetMeteringHighWaterMark(meteringPointId)
+	if err != nil {
+		return err
+	}
+
+	chunks := splitDateRange(from, to, settings.ElOverblik.MaxDaysPerRequest)
+	for i, chunk := range chunks {
+		if len(chunks) > 1 {
+			log.Println("Getting readings for meteringpoint", meteringPointId, "chunk", strconv.Itoa(i+1)+"/"+strconv.Itoa(len(chunks)))
+		}
+		meterReadings, err := eo.GetMeterReadings(ctx, meteringPointId, chunk.From, chunk.To)
+		if err != nil {
+			return err
+		}
+		if len(meterReadings.Result) == 0 {
+			continue
+		}
+
+		err = db.SaveMeteringTimeSeries(meterReadings)
+		if err != nil {
+			return err
+		}
+
+		latest, ok := latestReadingHour(meterReadings)
+		if ok && latest.After(lastHour) {
+			lastHour = latest
+			err = db.SaveMeteringHighWaterMark(meteringPointId, lastHour)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// latestReadingHour returns the start of the latest hour in the time-series result
+func latestReadingHour(mts EloverblikMeteringTimeSeriesResult) (latest time.Time, ok bool) {
+	for _, result := range mts.Result {
+		for _, ts := range result.MyEnergyDataMarketDocument.TimeSeries {
+			for _, p := range ts.Period {
+				for _, point := range p.Point {
+					pos, err := strconv.Atoi(point.Position)
+					if err != nil {
+						continue
+					}
+					// positions start at 1, and position 1 is the hour starting at TimeInterval.Start
+					hour := p.TimeInterval.Start.Add(time.Duration(pos-1) * time.Hour)
+					if !ok || hour.After(latest) {
+						latest = hour
+						ok = true
+					}
+				}
+			}
+		}
+	}
+	return latest, ok
+}
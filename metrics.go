@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// collectorMetrics keeps track of how the background collectors are doing, it's shared by
+// the collectors, the /metrics endpoint and the health checks
+var collectorMetrics = NewCollectorMetrics()
+
+// CollectorStats is the fetch statistics for a single collector and target, e.g. the
+// prices collector for DK1, or the eloverblik collector for a tenant
+type CollectorStats struct {
+	Collector     string
+	Target        string
+	Fetches       uint64
+	Errors        uint64
+	DurationSum   time.Duration
+	LastDuration  time.Duration
+	LastSuccess   time.Time
+	LastError     time.Time
+	LastErrorText string
+}
+
+// CollectorMetrics holds the statistics for every collector and target
+type CollectorMetrics struct {
+	lock  sync.RWMutex
+	stats map[string]*CollectorStats
+}
+
+// NewCollectorMetrics creates an empty CollectorMetrics
+func NewCollectorMetrics() *CollectorMetrics {
+	return &CollectorMetrics{stats: make(map[string]*CollectorStats)}
+}
+
+// Record registers a fetch that took duration, err is nil if it succeeded
+func (cm *CollectorMetrics) Record(collector string, target string, duration time.Duration, err error) {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+
+	key := collector + "/" + target
+	s, ok := cm.stats[key]
+	if !ok {
+		s = &CollectorStats{Collector: collector, Target: target}
+		cm.stats[key] = s
+	}
+
+	s.Fetches++
+	s.DurationSum += duration
+	s.LastDuration = duration
+	if err != nil {
+		s.Errors++
+		s.LastError = time.Now()
+		s.LastErrorText = err.Error()
+		return
+	}
+	s.LastSuccess = time.Now()
+}
+
+// Get returns the statistics for the collector and target, ok is false if nothing has been recorded
+func (cm *CollectorMetrics) Get(collector string, target string) (stats CollectorStats, ok bool) {
+	cm.lock.RLock()
+	defer cm.lock.RUnlock()
+
+	s, ok := cm.stats[collector+"/"+target]
+	if !ok {
+		return CollectorStats{Collector: collector, Target: target}, false
+	}
+	return *s, true
+}
+
+// Stats returns a copy of the statistics, sorted by collector and target
+func (cm *CollectorMetrics) Stats() []CollectorStats {
+	cm.lock.RLock()
+	defer cm.lock.RUnlock()
+
+	res := make([]CollectorStats, 0, len(cm.stats))
+	for _, s := range cm.stats {
+		res = append(res, *s)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Collector != res[j].Collector {
+			return res[i].Collector < res[j].Collector
+		}
+		return res[i].Target < res[j].Target
+	})
+	return res
+}
+
+// metricsWriter writes metrics in the prometheus text format
+type metricsWriter struct {
+	w io.Writer
+}
+
+// header writes the HELP and TYPE lines for a metric
+func (mw metricsWriter) header(name string, metricType string, help string) {
+	fmt.Fprintf(mw.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+// sample writes a single sample, labels are given as name, value pairs
+func (mw metricsWriter) sample(name string, value float64, labels ...string) {
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		v := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[i+1])
+		pairs = append(pairs, labels[i]+`="`+v+`"`)
+	}
+	if len(pairs) > 0 {
+		fmt.Fprintf(mw.w, "%s{%s} %g\n", name, strings.Join(pairs, ","), value)
+		return
+	}
+	fmt.Fprintf(mw.w, "%s %g\n", name, value)
+}
+
+// HandleGETMetrics returns the metrics in the prometheus text format
+func (a *API) HandleGETMetrics(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderContentType, "text/plain; version=0.0.4; charset=utf-8")
+	c.Response().WriteHeader(http.StatusOK)
+	mw := metricsWriter{w: c.Response()}
+
+	// the spot price for the current hour in each sector
+	now := time.Now().Truncate(time.Hour)
+	mw.header("lighthouse_spot_price_ore_per_kwh", "gauge", "The spot price for the current hour in øre/kWh.")
+	for _, sector := range a.Settings.NorlysAPI.Sectors {
+		prices, err := a.DB.GetSpotPrices(sector, now, now.Add(time.Hour))
+		if err != nil {
+			c.Logger().Error("error getting spot prices for metrics: ", err.Error())
+			continue
+		}
+		for _, p := range prices {
+			mw.sample("lighthouse_spot_price_ore_per_kwh", p.Price, "sector", sector)
+		}
+	}
+
+	// the latest stored consumption for each meteringpoint the user can see
+	readings, err := a.DB.GetLatestMeteringReadings()
+	if err != nil {
+		c.Logger().Error("error getting latest readings for metrics: ", err.Error())
+	}
+	allowed := a.allowedTenants(c)
+	if allowed != nil {
+		readings, err = a.filterReadings(readings, allowed)
+		if err != nil {
+			c.Logger().Error("error getting meteringpoints for metrics: ", err.Error())
+		}
+	}
+	mw.header("lighthouse_consumption_latest_hour_kwh", "gauge", "The consumption in the latest hour stored for the meteringpoint.")
+	for _, r := range readings {
+		mw.sample("lighthouse_consumption_latest_hour_kwh", r.Quantity, "meteringpoint", r.MeteringPointId)
+	}
+	mw.header("lighthouse_consumption_latest_hour_timestamp_seconds", "gauge", "The start of the latest hour stored for the meteringpoint.")
+	for _, r := range readings {
+		mw.sample("lighthouse_consumption_latest_hour_timestamp_seconds", float64(r.Hour.Unix()), "meteringpoint", r.MeteringPointId)
+	}
+
+	// the collector statistics, the eloverblik collectors are per tenant
+	allowedTargets := make(map[string]bool)
+	for _, t := range allowed {
+		allowedTargets[t] = true
+	}
+	stats := make([]CollectorStats, 0)
+	for _, s := range collectorMetrics.Stats() {
+		if s.Collector != "eloverblik" || allowed == nil || allowedTargets[s.Target] {
+			stats = append(stats, s)
+		}
+	}
+	mw.header("lighthouse_collector_fetch_duration_seconds", "summary", "The time spent fetching data from the upstream APIs.")
+	for _, s := range stats {
+		mw.sample("lighthouse_collector_fetch_duration_seconds_sum", s.DurationSum.Seconds(), "collector", s.Collector, "target", s.Target)
+		mw.sample("lighthouse_collector_fetch_duration_seconds_count", float64(s.Fetches), "collector", s.Collector, "target", s.Target)
+	}
+	mw.header("lighthouse_collector_errors_total", "counter", "The number of failed fetches from the upstream APIs.")
+	for _, s := range stats {
+		mw.sample("lighthouse_collector_errors_total", float64(s.Errors), "collector", s.Collector, "target", s.Target)
+	}
+	mw.header("lighthouse_collector_last_success_timestamp_seconds", "gauge", "The time of the last successful fetch, 0 if there hasn't been one.")
+	for _, s := range stats {
+		var ts float64
+		if !s.LastSuccess.IsZero() {
+			ts = float64(s.LastSuccess.Unix())
+		}
+		mw.sample("lighthouse_collector_last_success_timestamp_seconds", ts, "collector", s.Collector, "target", s.Target)
+	}
+
+	return nil
+}
+
+// filterReadings returns the readings of the meteringpoints belonging to the tenants
+func (a *API) filterReadings(readings []MeteringTimeSeriesEntry, tenants []string) ([]MeteringTimeSeriesEntry, error) {
+	res := make([]MeteringTimeSeriesEntry, 0, len(readings))
+	mps, err := a.DB.GetMeteringPoints(tenants)
+	if err != nil {
+		return res, err
+	}
+	ids := make(map[string]bool)
+	for _, mp := range mps {
+		ids[mp.MeteringPointId] = true
+	}
+	for _, r := range readings {
+		if ids[r.MeteringPointId] {
+			res = append(res, r)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles contains the schema migrations for each of the supported databases,
+// the files are named <version>_<description>.sql, and are applied in version order
+//
+//go:embed db/migrations
+var migrationFiles embed.FS
+
+// migration is a single versioned schema change
+type migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// loadMigrations reads the embedded migrations for the dialect, sorted by version
+func loadMigrations(dialect string) ([]migration, error) {
+	dir := path.Join("db/migrations", dialect)
+	entries, err := migrationFiles.ReadDir(dir)
+	if err != nil {
+		return nil, errors.New("no migrations found for " + dialect + ": " + err.Error())
+	}
+
+	migrations := make([]migration, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+
+		// the version is the number before the first underscore
+		prefix, _, _ := strings.Cut(entry.Name(), "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, errors.New("invalid migration filename: " + entry.Name())
+		}
+
+		content, err := migrationFiles.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, migration{Version: version, Name: entry.Name(), SQL: string(content)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// runMigrations applies every migration newer than the version recorded in the
+// schema_migrations table, and records each version as it's applied
+// MySQL commits implicitly after each DDL statement, so a failed migration is only partly
+// rolled back, and is run again from the start, the MySQL migrations must be idempotent
+func runMigrations(handle *sql.DB, dialect string) error {
+	migrations, err := loadMigrations(dialect)
+	if err != nil {
+		return err
+	}
+
+	_, err = handle.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL PRIMARY KEY, appliedAt DATETIME NOT NULL)")
+	if err != nil {
+		return errors.New("unable to create schema_migrations table: " + err.Error())
+	}
+
+	var current int
+	err = handle.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current)
+	if err != nil {
+		return errors.New("unable to read schema version: " + err.Error())
+	}
+
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+
+		log.Println("Applying database migration", m.Name)
+		tx, err := handle.Begin()
+		if err != nil {
+			return err
+		}
+
+		// the drivers only accept a single statement per Exec, so let's split the file
+		for _, stmt := range strings.Split(m.SQL, ";") {
+			if strings.TrimSpace(stmt) == "" {
+				continue
+			}
+			_, err = tx.Exec(stmt)
+			if err != nil {
+				_ = tx.Rollback()
+				return errors.New("migration " + m.Name + " failed: " + err.Error())
+			}
+		}
+
+		_, err = tx.Exec("INSERT INTO schema_migrations (version, appliedAt) VALUES (?, ?)", m.Version, time.Now().UTC())
+		if err != nil {
+			_ = tx.Rollback()
+			return errors.New("unable to record migration " + m.Name + ": " + err.Error())
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return err
+		}
+		current = m.Version
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
@@ -11,32 +12,45 @@ import (
 
 // NorlysAPI contains all functions needed to get pricing information from Norlys
 type NorlysAPI struct {
+	URL   string
+	Retry RetryPolicy
 }
 
 // NorlysPricingResult contains the prices in DKK øre for the Date specified in PriceDate
 // Sector DK1 is West denmark and DK2 is east denmark
 type NorlysPricingResult struct {
-	PriceDate     time.Time `json:"PriceDate"`
-	Sector        string    `json:"Sector"`
-	Currency      string    `json:"Currency"`
-	DisplayPrices []struct {
-		Time  string  `json:"Time"`
-		Value float64 `json:"Value"`
-	} `json:"DisplayPrices"`
+	PriceDate     time.Time            `json:"PriceDate"`
+	Sector        string               `json:"Sector"`
+	Currency      string               `json:"Currency"`
+	DisplayPrices []NorlysDisplayPrice `json:"DisplayPrices"`
 }
 
-// GetPrices Makes a HTTP request towards the norlys API, and returns the FlexEl prices.
-func (n *NorlysAPI) GetPrices(numberOfDays int, settings *Settings) (res []NorlysPricingResult, err error) {
+// NorlysDisplayPrice is the price for a single hour, Time is the number of hours since PriceDate
+type NorlysDisplayPrice struct {
+	Time  string  `json:"Time"`
+	Value float64 `json:"Value"`
+}
+
+// Name returns the name of the price provider
+func (n *NorlysAPI) Name() string {
+	return "Norlys"
+}
+
+// GetPrices Makes a HTTP request towards the norlys API, and returns the FlexEl prices for the sector.
+func (n *NorlysAPI) GetPrices(ctx context.Context, numberOfDays int, sector string) (res []NorlysPricingResult, err error) {
 	res = make([]NorlysPricingResult, 0)
 
 	// Generate the URL
-	url := settings.NorlysAPI.URL + "days=" + strconv.Itoa(numberOfDays) + "&sector=DK1"
+	url := n.URL + "days=" + strconv.Itoa(numberOfDays) + "&sector=" + sector
 
-	// Make the HTTP call towards the Norlys API
-	resp, err := http.Get(url)
+	// Make the HTTP call towards the Norlys API, retrying if it's unavailable
+	resp, err := n.Retry.Do(ctx, func(ctx context.Context) (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	})
 	if err != nil {
 		return res, err
 	}
+	defer resp.Body.Close()
 
 	// check response code
 	if resp.StatusCode >= 300 {
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NotificationTarget is where notifications are pushed to
+type NotificationTarget struct {
+	Name  string `toml:"Name"`
+	Type  string `toml:"Type"`  // ntfy or gotify
+	URL   string `toml:"URL"`   // the ntfy topic URL, or the gotify server URL
+	Token string `toml:"Token"` // ntfy access token, or gotify application token
+}
+
+// NotificationRule is evaluated when tomorrow's prices are stored, all prices are the
+// all-in prices in øre/kWh, using the grid tariffs of MeteringPointId if configured
+type NotificationRule struct {
+	Name            string   `toml:"Name"`
+	Type            string   `toml:"Type"` // priceBelow, priceAbove, cheapestWindow or newPrices
+	Sector          string   `toml:"Sector"`
+	MeteringPointId string   `toml:"MeteringPointId"`
+	Threshold       float64  `toml:"Threshold"` // used by priceBelow and priceAbove
+	Duration        string   `toml:"Duration"`  // used by cheapestWindow, e.g. 3h
+	Targets         []string `toml:"Targets"`
+}
+
+// Notification is a single message to push
+type Notification struct {
+	Title   string
+	Message string
+}
+
+// Notifier evaluates the notification rules and pushes the notifications to the targets,
+// each notification is only sent once, so evaluating again after a failed push is fine
+type Notifier struct {
+	settings *Settings
+	db       Store
+	client   *http.Client
+
+	lock sync.Mutex
+	sent map[string]time.Time
+}
+
+// NewNotifier creates a Notifier for the rules and targets in the settings
+func NewNotifier(settings *Settings, db Store) *Notifier {
+	return &Notifier{
+		settings: settings,
+		db:       db,
+		client:   &http.Client{Timeout: 20 * time.Second},
+		sent:     make(map[string]time.Time),
+	}
+}
+
+// Evaluate checks every rule against the prices stored from the hour of now, and pushes the
+// notifications that hasn't been sent before, it returns false if some of them weren't delivered
+func (n *Notifier) Evaluate(ctx context.Context, now time.Time) bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	delivered := true
+	for _, rule := range n.settings.Notifications.Rules {
+		notifications, err := n.evaluateRule(rule, now)
+		if err != nil {
+			log.Println("Error evaluating notification rule", rule.Name+":", err.Error())
+			delivered = false
+			continue
+		}
+
+		for key, notification := range notifications {
+			if _, ok := n.sent[key]; ok {
+				continue
+			}
+			if n.push(ctx, rule, notification) {
+				n.sent[key] = now
+			} else {
+				delivered = false
+			}
+		}
+	}
+
+	// forget the notifications sent more than a few days ago, as they won't trigger again
+	for key, sent := range n.sent {
+		if now.Sub(sent) > 72*time.Hour {
+			delete(n.sent, key)
+		}
+	}
+	return delivered
+}
+
+// evaluateRule returns the notifications for the rule, keyed by a string that identifies
+// the notification, so it's only sent once
+func (n *Notifier) evaluateRule(rule NotificationRule, now time.Time) (map[string]Notification, error) {
+	res := make(map[string]Notification)
+
+	sector := rule.Sector
+	if sector == "" {
+		sector = n.settings.NorlysAPI.Sectors[0]
+	}
+
+	// the upcoming prices, starting with the current hour
+	from := now.Truncate(time.Hour)
+	to := from.AddDate(0, 0, 3)
+	prices, err := n.prices(rule, sector, from, to)
+	if err != nil {
+		return res, err
+	}
+
+	switch rule.Type {
+	case "priceBelow", "priceAbove":
+		// group the matching hours by danish date, so there is one notification per day
+		matches := make(map[string][]HourPrice)
+		for _, p := range prices {
+			if (rule.Type == "priceBelow" && p.Total < rule.Threshold) || (rule.Type == "priceAbove" && p.Total > rule.Threshold) {
+				day := p.Hour.In(danishTime).Format("2006-01-02")
+				matches[day] = append(matches[day], p)
+			}
+		}
+		for day, hours := range matches {
+			direction := "below"
+			if rule.Type == "priceAbove" {
+				direction = "above"
+			}
+			res[rule.Name+"/"+day] = Notification{
+				Title:   fmt.Sprintf("%s: price %s %.1f øre/kWh", rule.Name, direction, rule.Threshold),
+				Message: fmt.Sprintf("%s %s: %s", sector, day, formatHours(hours)),
+			}
+		}
+
+	case "cheapestWindow":
+		duration, _ := time.ParseDuration(rule.Duration)
+		hours := int(duration / time.Hour)
+
+		// only use tomorrow's prices, so the notification is sent when they are published
+		tomorrow, tomorrowPrices := tomorrowsPrices(prices, now)
+		window, ok := CheapestWindow(tomorrowPrices, hours)
+		if !ok {
+			return res, nil
+		}
+		res[rule.Name+"/"+tomorrow.Format("2006-01-02")] = Notification{
+			Title: fmt.Sprintf("%s: cheapest %s tomorrow", rule.Name, rule.Duration),
+			Message: fmt.Sprintf("%s %s: %s-%s, average %.1f øre/kWh", sector, tomorrow.Format("2006-01-02"),
+				window.Start.In(danishTime).Format("15:04"), window.End.In(danishTime).Format("15:04"), window.AveragePrice),
+		}
+
+	case "newPrices":
+		// sent once all of tomorrow's prices has been published
+		tomorrow, tomorrowPrices := tomorrowsPrices(prices, now)
+		if len(tomorrowPrices) < minHoursPerDay {
+			return res, nil
+		}
+		lowest, highest, sum := tomorrowPrices[0].Total, tomorrowPrices[0].Total, 0.0
+		for _, p := range tomorrowPrices {
+			lowest = math.Min(lowest, p.Total)
+			highest = math.Max(highest, p.Total)
+			sum += p.Total
+		}
+		res[rule.Name+"/"+tomorrow.Format("2006-01-02")] = Notification{
+			Title: fmt.Sprintf("%s: prices for tomorrow are available", rule.Name),
+			Message: fmt.Sprintf("%s %s: lowest %.1f, average %.1f, highest %.1f øre/kWh", sector, tomorrow.Format("2006-01-02"),
+				lowest, sum/float64(len(tomorrowPrices)), highest),
+		}
+	}
+
+	return res, nil
+}
+
+// tomorrowsPrices returns the danish date of tomorrow, and the prices for that day
+func tomorrowsPrices(prices []HourPrice, now time.Time) (time.Time, []HourPrice) {
+	dk := now.In(danishTime)
+	tomorrow := time.Date(dk.Year(), dk.Month(), dk.Day()+1, 0, 0, 0, 0, danishTime)
+	dayAfter := tomorrow.AddDate(0, 0, 1)
+	res := make([]HourPrice, 0)
+	for _, p := range prices {
+		if !p.Hour.Before(tomorrow) && p.Hour.Before(dayAfter) {
+			res = append(res, p)
+		}
+	}
+	return tomorrow, res
+}
+
+// prices returns the all-in prices for the rule, where from <= hour < to
+func (n *Notifier) prices(rule NotificationRule, sector string, from time.Time, to time.Time) ([]HourPrice, error) {
+	tariffs := make([]StoredTariff, 0)
+	if rule.MeteringPointId != "" {
+		var err error
+		tariffs, err = n.db.GetTariffs(rule.MeteringPointId, from, to)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	spotPrices, err := n.db.GetSpotPrices(sector, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	pc := NewPriceCalculator(n.settings, tariffs)
+	prices := make([]HourPrice, 0, len(spotPrices))
+	for _, sp := range spotPrices {
+		prices = append(prices, pc.Price(sp.Hour, sp.Price))
+	}
+	return prices, nil
+}
+
+// formatHours lists the hours as danish time, joining consecutive hours into ranges
+func formatHours(hours []HourPrice) string {
+	ranges := make([]string, 0)
+	for i := 0; i < len(hours); i++ {
+		start := i
+		for i+1 < len(hours) && hours[i+1].Hour.Equal(hours[i].Hour.Add(time.Hour)) {
+			i++
+		}
+		ranges = append(ranges, hours[start].Hour.In(danishTime).Format("15:04")+"-"+hours[i].Hour.Add(time.Hour).In(danishTime).Format("15:04"))
+	}
+	return strings.Join(ranges, ", ")
+}
+
+// push sends the notification to each of the targets of the rule, and returns true if it
+// was delivered to at least one of them
+func (n *Notifier) push(ctx context.Context, rule NotificationRule, notification Notification) bool {
+	delivered := false
+	for _, name := range rule.Targets {
+		for _, target := range n.settings.Notifications.Targets {
+			if target.Name != name {
+				continue
+			}
+
+			err := n.pushToTarget(ctx, target, notification)
+			if err != nil {
+				log.Println("Error pushing notification to", target.Name+":", err.Error())
+				continue
+			}
+			delivered = true
+		}
+	}
+	return delivered
+}
+
+// pushToTarget makes the HTTP request towards a ntfy or gotify server
+func (n *Notifier) pushToTarget(ctx context.Context, target NotificationTarget, notification Notification) error {
+
+	// create the context, timeout after 20 seconds
+	timeoutContext, cancelFunc := context.WithTimeout(ctx, 20*time.Second)
+	defer cancelFunc()
+
+	var req *http.Request
+	var err error
+	switch target.Type {
+	case "ntfy":
+		req, err = http.NewRequestWithContext(timeoutContext, http.MethodPost, target.URL, strings.NewReader(notification.Message))
+		if err != nil {
+			return err
+		}
+		// headers must be ascii, and the title might contain øre
+		req.Header.Add("Title", mime.QEncoding.Encode("utf-8", notification.Title))
+		if target.Token != "" {
+			req.Header.Add("Authorization", "Bearer "+target.Token)
+		}
+
+	case "gotify":
+		bjson, err := json.Marshal(map[string]interface{}{
+			"title":    notification.Title,
+			"message":  notification.Message,
+			"priority": 5,
+		})
+		if err != nil {
+			return err
+		}
+		req, err = http.NewRequestWithContext(timeoutContext, http.MethodPost, strings.TrimSuffix(target.URL, "/")+"/message", bytes.NewBuffer(bjson))
+		if err != nil {
+			return err
+		}
+		req.Header.Add("Content-Type", "application/json")
+		req.Header.Add("X-Gotify-Key", target.Token)
+
+	default:
+		return errors.New("unknown notification target type: " + target.Type)
+	}
+
+	res, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode > 299 {
+		return errors.New("unable to push notification, server responded:" + res.Status)
+	}
+	return nil
+}
+
+// validateNotifications checks the notification rules and targets in the settings
+func validateNotifications(s *Settings) []error {
+	var errs []error
+	targets := make(map[string]bool)
+	for _, t := range s.Notifications.Targets {
+		if t.Name == "" {
+			errs = append(errs, errors.New("notification target name not configured"))
+		}
+		if t.Type != "ntfy" && t.Type != "gotify" {
+			errs = append(errs, errors.New("notification target "+t.Name+" type must be ntfy or gotify, got: "+t.Type))
+		}
+		if t.URL == "" {
+			errs = append(errs, errors.New("notification target "+t.Name+" url not configured"))
+		}
+		targets[t.Name] = true
+	}
+
+	for _, r := range s.Notifications.Rules {
+		if r.Name == "" {
+			errs = append(errs, errors.New("notification rule name not configured"))
+		}
+		switch r.Type {
+		case "priceBelow", "priceAbove", "newPrices":
+		case "cheapestWindow":
+			d, err := time.ParseDuration(r.Duration)
+			if err != nil || d < time.Hour || d%time.Hour != 0 {
+				errs = append(errs, errors.New("notification rule "+r.Name+" duration must be a whole number of hours, e.g. 3h"))
+			}
+		default:
+			errs = append(errs, errors.New("notification rule "+r.Name+" type must be priceBelow, priceAbove, cheapestWindow or newPrices, got: "+r.Type))
+		}
+		if r.Sector != "" && !validSector(r.Sector) {
+			errs = append(errs, errors.New("notification rule "+r.Name+" sector must be DK1 or DK2, got: "+r.Sector))
+		}
+		if len(r.Targets) == 0 {
+			errs = append(errs, errors.New("notification rule "+r.Name+" has no targets"))
+		}
+		for _, t := range r.Targets {
+			if !targets[t] {
+				errs = append(errs, errors.New("notification rule "+r.Name+" uses unknown target: "+t))
+			}
+		}
+	}
+
+	return errs
+}
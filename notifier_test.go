@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pushStandIn records the notifications pushed to it, and responds with the next of the
+// status codes, or 200 when there are no more
+type pushStandIn struct {
+	lock     sync.Mutex
+	statuses []int
+	pushed   []Notification
+	headers  []http.Header
+}
+
+func (s *pushStandIn) handle(t *testing.T, gotify bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		body, _ := io.ReadAll(r.Body)
+		n := Notification{Message: string(body)}
+		if gotify {
+			if r.URL.Path != "/message" {
+				t.Errorf("gotify: unexpected path %s", r.URL.Path)
+			}
+			var msg struct {
+				Title   string `json:"title"`
+				Message string `json:"message"`
+			}
+			if err := json.Unmarshal(body, &msg); err != nil {
+				t.Errorf("gotify: invalid body %s", body)
+			}
+			n = Notification{Title: msg.Title, Message: msg.Message}
+		} else {
+			title, err := new(mime.WordDecoder).DecodeHeader(r.Header.Get("Title"))
+			if err != nil {
+				t.Errorf("ntfy: invalid title header %s", r.Header.Get("Title"))
+			}
+			n.Title = title
+		}
+
+		status := http.StatusOK
+		if len(s.statuses) > 0 {
+			status, s.statuses = s.statuses[0], s.statuses[1:]
+		}
+		if status == http.StatusOK {
+			s.pushed = append(s.pushed, n)
+			s.headers = append(s.headers, r.Header.Clone())
+		}
+		w.WriteHeader(status)
+	}
+}
+
+func (s *pushStandIn) delivered() []Notification {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]Notification(nil), s.pushed...)
+}
+
+func TestNotifierEvaluate(t *testing.T) {
+	tests := []struct {
+		name       string
+		now        time.Time
+		cheapHours string // the hours from 2 to 5 hours after midnight tomorrow
+		average    string
+		numHours   int
+	}{
+		{name: "normal day", now: time.Date(2025, 11, 9, 15, 0, 0, 0, danishTime), cheapHours: "02:00-05:00", average: "93.8", numHours: 24},
+		{name: "long DST day", now: time.Date(2025, 10, 25, 15, 0, 0, 0, danishTime), cheapHours: "02:00-04:00", average: "94.0", numHours: 25},
+		{name: "short DST day", now: time.Date(2025, 3, 29, 15, 0, 0, 0, danishTime), cheapHours: "03:00-06:00", average: "93.5", numHours: 23},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings, db := newTestStore(t)
+			settings.NorlysAPI.Sectors = []string{"DK1"}
+			settings.Pricing.VATPercent = 25
+
+			// tomorrow's spot prices are 80 øre/kWh, and 40 øre/kWh from 2 to 5 hours after midnight,
+			// 100 and 50 with VAT
+			dk := tt.now.In(danishTime)
+			tomorrow := time.Date(dk.Year(), dk.Month(), dk.Day()+1, 0, 0, 0, 0, danishTime)
+			prices := make([]float64, tt.numHours)
+			for i := range prices {
+				prices[i] = 80
+				if i >= 2 && i < 5 {
+					prices[i] = 40
+				}
+			}
+			saveTestPrices(t, db, "DK1", tomorrow, prices...)
+
+			// the ntfy server fails the first push, so it must be retried by the next Evaluate
+			ntfy := &pushStandIn{statuses: []int{http.StatusInternalServerError}}
+			ntfyServer := httptest.NewServer(ntfy.handle(t, false))
+			defer ntfyServer.Close()
+			gotify := &pushStandIn{}
+			gotifyServer := httptest.NewServer(gotify.handle(t, true))
+			defer gotifyServer.Close()
+
+			settings.Notifications.Targets = []NotificationTarget{
+				{Name: "phone", Type: "ntfy", URL: ntfyServer.URL + "/lighthouse", Token: "ntfy-token"},
+				{Name: "desktop", Type: "gotify", URL: gotifyServer.URL + "/", Token: "gotify-token"},
+			}
+			settings.Notifications.Rules = []NotificationRule{
+				{Name: "cheap", Type: "priceBelow", Threshold: 60, Targets: []string{"phone"}},
+				{Name: "new", Type: "newPrices", Targets: []string{"desktop"}},
+			}
+			notifier := NewNotifier(settings, db)
+
+			date := tomorrow.Format("2006-01-02")
+			cheap := Notification{Title: "cheap: price below 60.0 øre/kWh", Message: "DK1 " + date + ": " + tt.cheapHours}
+			newPrices := Notification{Title: "new: prices for tomorrow are available", Message: "DK1 " + date + ": lowest 50.0, average " + tt.average + ", highest 100.0 øre/kWh"}
+
+			evaluations := []struct {
+				name          string
+				now           time.Time
+				wantDelivered bool
+				wantNtfy      []Notification
+				wantGotify    []Notification
+			}{
+				{name: "first evaluation, ntfy fails", now: tt.now, wantDelivered: false, wantNtfy: nil, wantGotify: []Notification{newPrices}},
+				{name: "second evaluation retries ntfy", now: tt.now.Add(5 * time.Minute), wantDelivered: true, wantNtfy: []Notification{cheap}, wantGotify: []Notification{newPrices}},
+				{name: "third evaluation sends nothing new", now: tt.now.Add(time.Hour), wantDelivered: true, wantNtfy: []Notification{cheap}, wantGotify: []Notification{newPrices}},
+			}
+
+			for _, ev := range evaluations {
+				if got := notifier.Evaluate(context.Background(), ev.now); got != ev.wantDelivered {
+					t.Errorf("%s: Evaluate returned %v, want %v", ev.name, got, ev.wantDelivered)
+				}
+
+				for _, c := range []struct {
+					target string
+					got    []Notification
+					want   []Notification
+				}{
+					{"ntfy", ntfy.delivered(), ev.wantNtfy},
+					{"gotify", gotify.delivered(), ev.wantGotify},
+				} {
+					if len(c.got) != len(c.want) {
+						t.Fatalf("%s: %s got %v, want %v", ev.name, c.target, c.got, c.want)
+					}
+					for i := range c.got {
+						if c.got[i] != c.want[i] {
+							t.Errorf("%s: %s got %+v, want %+v", ev.name, c.target, c.got[i], c.want[i])
+						}
+					}
+				}
+			}
+
+			if got := ntfy.headers[0].Get("Authorization"); got != "Bearer ntfy-token" {
+				t.Errorf("ntfy: got Authorization %q", got)
+			}
+			if got := gotify.headers[0].Get("X-Gotify-Key"); got != "gotify-token" {
+				t.Errorf("gotify: got X-Gotify-Key %q", got)
+			}
+		})
+	}
+}
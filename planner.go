@@ -0,0 +1,91 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// PlanHours is a set of hours picked by the planner, with the average price in øre/kWh
+type PlanHours struct {
+	Start        time.Time   `json:"start"`
+	End          time.Time   `json:"end"`
+	AveragePrice float64     `json:"averagePrice"`
+	Hours        []HourPrice `json:"hours"`
+}
+
+// CheapestWindow finds the contiguous run of hours with the lowest total price, the prices
+// must be sorted by hour, and hours missing in the prices breaks a run
+// false is returned if there is no run of the requested length
+func CheapestWindow(prices []HourPrice, hours int) (PlanHours, bool) {
+	if hours <= 0 {
+		return PlanHours{}, false
+	}
+
+	best := -1
+	var bestSum float64
+
+	for start := 0; start+hours <= len(prices); start++ {
+		var sum float64
+		contiguous := true
+		for i := start; i < start+hours; i++ {
+			if i > start && !prices[i].Hour.Equal(prices[i-1].Hour.Add(time.Hour)) {
+				contiguous = false
+				break
+			}
+			sum += prices[i].Total
+		}
+		if contiguous && (best == -1 || sum < bestSum) {
+			best = start
+			bestSum = sum
+		}
+	}
+
+	if best == -1 {
+		return PlanHours{}, false
+	}
+	return newPlanHours(prices[best : best+hours]), true
+}
+
+// CheapestHours picks the hours with the lowest prices, the result is sorted by hour
+// false is returned if there isn't enough prices
+func CheapestHours(prices []HourPrice, hours int) (PlanHours, bool) {
+	if hours > len(prices) || hours <= 0 {
+		return PlanHours{}, false
+	}
+
+	sorted := make([]HourPrice, len(prices))
+	copy(sorted, prices)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Total < sorted[j].Total
+	})
+
+	picked := sorted[:hours]
+	sort.Slice(picked, func(i, j int) bool {
+		return picked[i].Hour.Before(picked[j].Hour)
+	})
+	return newPlanHours(picked), true
+}
+
+// newPlanHours creates the PlanHours for the hours, which must be sorted by hour
+func newPlanHours(hours []HourPrice) PlanHours {
+	p := PlanHours{
+		Start: hours[0].Hour,
+		End:   hours[len(hours)-1].Hour.Add(time.Hour),
+		Hours: hours,
+	}
+	for _, h := range hours {
+		p.AveragePrice += h.Total
+	}
+	p.AveragePrice /= float64(len(hours))
+	return p
+}
+
+// nextOccurrence returns the first time after t where the danish wall clock shows hour:minute
+func nextOccurrence(t time.Time, hour int, minute int) time.Time {
+	dk := t.In(danishTime)
+	next := time.Date(dk.Year(), dk.Month(), dk.Day(), hour, minute, 0, 0, danishTime)
+	if !next.After(t) {
+		next = time.Date(dk.Year(), dk.Month(), dk.Day()+1, hour, minute, 0, 0, danishTime)
+	}
+	return next
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// testPrices returns a price for each hour from start, skipping the hours where the total is negative
+func testPrices(start time.Time, totals ...float64) []HourPrice {
+	prices := make([]HourPrice, 0, len(totals))
+	for i, total := range totals {
+		if total < 0 {
+			continue
+		}
+		prices = append(prices, HourPrice{Hour: start.Add(time.Duration(i) * time.Hour), Total: total})
+	}
+	return prices
+}
+
+func TestCheapestWindow(t *testing.T) {
+	start := time.Date(2025, 11, 10, 0, 0, 0, 0, danishTime)
+	tests := []struct {
+		name      string
+		prices    []HourPrice
+		hours     int
+		wantOk    bool
+		wantStart int // hours after start
+		wantAvg   float64
+	}{
+		{name: "single hour", prices: testPrices(start, 5, 3, 4), hours: 1, wantOk: true, wantStart: 1, wantAvg: 3},
+		{name: "window", prices: testPrices(start, 1, 9, 9, 2, 2, 2, 9), hours: 3, wantOk: true, wantStart: 3, wantAvg: 2},
+		{name: "first of equal windows", prices: testPrices(start, 2, 2, 9, 2, 2), hours: 2, wantOk: true, wantStart: 0, wantAvg: 2},
+		{name: "missing hour breaks the run", prices: testPrices(start, 1, -1, 1, 5, 5), hours: 2, wantOk: true, wantStart: 2, wantAvg: 3},
+		{name: "all prices", prices: testPrices(start, 1, 2, 3), hours: 3, wantOk: true, wantStart: 0, wantAvg: 2},
+		{name: "too few prices", prices: testPrices(start, 1, 2), hours: 3, wantOk: false},
+		{name: "no contiguous run", prices: testPrices(start, 1, -1, 2, -1, 3), hours: 2, wantOk: false},
+		{name: "no hours", prices: testPrices(start, 1, 2), hours: 0, wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := CheapestWindow(tt.prices, tt.hours)
+			if ok != tt.wantOk {
+				t.Fatalf("got ok %v, want %v", ok, tt.wantOk)
+			}
+			if !ok {
+				return
+			}
+			wantStart := start.Add(time.Duration(tt.wantStart) * time.Hour)
+			if !got.Start.Equal(wantStart) || !got.End.Equal(wantStart.Add(time.Duration(tt.hours)*time.Hour)) {
+				t.Errorf("got %v to %v, want %d hours from %v", got.Start, got.End, tt.hours, wantStart)
+			}
+			if len(got.Hours) != tt.hours || got.AveragePrice != tt.wantAvg {
+				t.Errorf("got %d hours averaging %v, want %d hours averaging %v", len(got.Hours), got.AveragePrice, tt.hours, tt.wantAvg)
+			}
+		})
+	}
+}
+
+func TestCheapestHours(t *testing.T) {
+	start := time.Date(2025, 11, 10, 0, 0, 0, 0, danishTime)
+	tests := []struct {
+		name      string
+		prices    []HourPrice
+		hours     int
+		wantOk    bool
+		wantHours []int // hours after start
+		wantAvg   float64
+	}{
+		{name: "cheapest hours sorted by hour", prices: testPrices(start, 5, 1, 9, 2, 7), hours: 2, wantOk: true, wantHours: []int{1, 3}, wantAvg: 1.5},
+		{name: "earliest of equal prices", prices: testPrices(start, 3, 1, 3, 3), hours: 2, wantOk: true, wantHours: []int{0, 1}, wantAvg: 2},
+		{name: "all prices", prices: testPrices(start, 3, 1), hours: 2, wantOk: true, wantHours: []int{0, 1}, wantAvg: 2},
+		{name: "too few prices", prices: testPrices(start, 3, 1), hours: 3, wantOk: false},
+		{name: "no hours", prices: testPrices(start, 3, 1), hours: 0, wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := CheapestHours(tt.prices, tt.hours)
+			if ok != tt.wantOk {
+				t.Fatalf("got ok %v, want %v", ok, tt.wantOk)
+			}
+			if !ok {
+				return
+			}
+			if len(got.Hours) != len(tt.wantHours) {
+				t.Fatalf("got %d hours, want %d", len(got.Hours), len(tt.wantHours))
+			}
+			for i, h := range tt.wantHours {
+				if want := start.Add(time.Duration(h) * time.Hour); !got.Hours[i].Hour.Equal(want) {
+					t.Errorf("hour %d is %v, want %v", i, got.Hours[i].Hour, want)
+				}
+			}
+			if got.AveragePrice != tt.wantAvg {
+				t.Errorf("got average %v, want %v", got.AveragePrice, tt.wantAvg)
+			}
+		})
+	}
+}
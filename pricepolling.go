@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// minHoursPerDay is the number of hours on the short day of the DST change, a day with fewer
+// prices hasn't been published completely
+const minHoursPerDay = 23
+
+// PricePoller decides when to poll for prices, the day-ahead prices for tomorrow are published around
+// 13:00, so it polls every PollInterval in the publication window until they are available, and then
+// waits until the window of the next day
+// If the prices haven't been published when the window ends, the schedule is used until they are
+type PricePoller struct {
+	Schedule     Schedule
+	WindowStart  timeOfDay
+	WindowEnd    timeOfDay
+	PollInterval time.Duration
+
+	// available is the danish date of the latest day we have found all prices for
+	available time.Time
+}
+
+// NewPricePoller creates the poller from the settings, which must have been validated
+func NewPricePoller(settings *Settings) *PricePoller {
+	schedule, _ := settings.PricesSchedule()
+	start, end, _ := parsePublicationWindow(settings.NorlysAPI.PublicationWindow)
+	return &PricePoller{
+		Schedule:     schedule,
+		WindowStart:  start,
+		WindowEnd:    end,
+		PollInterval: time.Duration(settings.NorlysAPI.PublicationPollInterval) * time.Second,
+	}
+}
+
+// Check looks up if all the prices for tomorrow are stored for each of the sectors, and returns
+// the danish date of tomorrow, and if they are, Next uses the result of the latest check
+func (p *PricePoller) Check(db Store, sectors []string, now time.Time) (time.Time, bool, error) {
+	tomorrow, stored, err := tomorrowsPricesStored(db, sectors, now)
+	if err == nil && stored {
+		p.available = tomorrow
+	}
+	return tomorrow, stored, err
+}
+
+// tomorrowsPricesStored returns the danish date of tomorrow, and true if all the prices for
+// tomorrow are stored for each of the sectors
+func tomorrowsPricesStored(db Store, sectors []string, now time.Time) (time.Time, bool, error) {
+	dk := now.In(danishTime)
+	tomorrow := time.Date(dk.Year(), dk.Month(), dk.Day()+1, 0, 0, 0, 0, danishTime)
+	for _, sector := range sectors {
+		prices, err := db.GetSpotPrices(sector, tomorrow, tomorrow.AddDate(0, 0, 1))
+		if err != nil {
+			return tomorrow, false, err
+		}
+		if len(prices) < minHoursPerDay {
+			return tomorrow, false, nil
+		}
+	}
+	return tomorrow, true, nil
+}
+
+// Next returns when to poll for prices after now
+func (p *PricePoller) Next(now time.Time) time.Time {
+	dk := now.In(danishTime)
+	tomorrow := time.Date(dk.Year(), dk.Month(), dk.Day()+1, 0, 0, 0, 0, danishTime)
+	windowStart := p.WindowStart.On(now, 0)
+	windowEnd := p.WindowEnd.On(now, 0)
+	nextWindowStart := p.WindowStart.On(now, 1)
+
+	switch {
+	case p.available.Equal(tomorrow):
+		// we have tomorrow's prices, so there is nothing new until the next window
+		return nextWindowStart
+	case now.Before(windowStart):
+		return windowStart
+	case now.Before(windowEnd):
+		return now.Add(p.PollInterval)
+	}
+
+	// the prices are late, keep polling on the schedule until they are available
+	next := p.Schedule.Next(now)
+	if next.IsZero() || nextWindowStart.Before(next) {
+		next = nextWindowStart
+	}
+	return next
+}
+
+// parsePublicationWindow parses the window given as 12:45-15:00, danish time
+func parsePublicationWindow(window string) (timeOfDay, timeOfDay, error) {
+	parts := strings.Split(window, "-")
+	if len(parts) != 2 {
+		return timeOfDay{}, timeOfDay{}, errors.New("must be given as 12:45-15:00, got: " + window)
+	}
+	start, err := parseTimeOfDay(parts[0])
+	if err != nil {
+		return timeOfDay{}, timeOfDay{}, errors.New("must be given as 12:45-15:00, got: " + window)
+	}
+	end, err := parseTimeOfDay(parts[1])
+	if err != nil {
+		return timeOfDay{}, timeOfDay{}, errors.New("must be given as 12:45-15:00, got: " + window)
+	}
+	if end.minutes() <= start.minutes() {
+		return start, end, errors.New("must end after it starts, got: " + window)
+	}
+	return start, end, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPricePollerNext(t *testing.T) {
+	dk := func(day int, hour int, minute int) time.Time {
+		return time.Date(2025, 11, day, hour, minute, 0, 0, danishTime)
+	}
+	tests := []struct {
+		name      string
+		interval  int
+		daily     string
+		now       time.Time
+		available time.Time
+		want      time.Time
+	}{
+		{name: "before the window", interval: 3600, now: dk(10, 10, 0), want: dk(10, 12, 45)},
+		{name: "in the window", interval: 3600, now: dk(10, 13, 0), want: dk(10, 13, 5)},
+		{name: "in the window with the prices", interval: 3600, now: dk(10, 13, 0), available: dk(11, 0, 0), want: dk(11, 12, 45)},
+		{name: "with yesterday's prices", interval: 3600, now: dk(10, 13, 0), available: dk(10, 0, 0), want: dk(10, 13, 5)},
+		{name: "late prices use the schedule", interval: 3600, now: dk(10, 16, 0), want: dk(10, 17, 0)},
+		{name: "late prices after midnight", interval: 3600, now: dk(10, 23, 30), want: dk(11, 0, 30)},
+		{name: "late prices with a daily schedule", daily: "06:00", now: dk(10, 16, 0), want: dk(11, 6, 0)},
+		{name: "next window before the schedule", daily: "18:00", now: dk(10, 19, 0), want: dk(11, 12, 45)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.interval, tt.daily)
+			if err != nil {
+				t.Fatalf("ParseSchedule returned an error: %v", err)
+			}
+			start, end, _ := parsePublicationWindow("12:45-15:00")
+			p := &PricePoller{Schedule: schedule, WindowStart: start, WindowEnd: end, PollInterval: 5 * time.Minute, available: tt.available}
+			if got := p.Next(tt.now); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPricePollerCheck(t *testing.T) {
+	settings := &Settings{}
+	settings.Database.Driver = "sqlite"
+	settings.Database.Path = filepath.Join(t.TempDir(), "lighthouse.db")
+	db, err := NewStore(settings)
+	if err != nil {
+		t.Fatalf("unable to create store: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2025, 10, 25, 13, 30, 0, 0, danishTime)
+	tomorrow := time.Date(2025, 10, 26, 0, 0, 0, 0, danishTime)
+	savePrices := func(sector string, hours int) {
+		pd := NorlysPricingResult{PriceDate: tomorrow, Sector: sector, Currency: priceCurrency}
+		for i := 0; i < hours; i++ {
+			pd.DisplayPrices = append(pd.DisplayPrices, NorlysDisplayPrice{Time: strconv.Itoa(i), Value: 100})
+		}
+		if err := db.SaveNorlysPricingResult(&pd); err != nil {
+			t.Fatalf("unable to save prices: %v", err)
+		}
+	}
+
+	p := &PricePoller{}
+	sectors := []string{"DK1", "DK2"}
+	tests := []struct {
+		name  string
+		setup func()
+		want  bool
+	}{
+		{name: "no prices", setup: func() {}, want: false},
+		{name: "part of the day", setup: func() { savePrices("DK1", 12) }, want: false},
+		{name: "one of the sectors", setup: func() { savePrices("DK1", 25) }, want: false},
+		{name: "all sectors", setup: func() { savePrices("DK2", 25) }, want: true},
+		{name: "still available", setup: func() {}, want: true},
+	}
+
+	for _, tt := range tests {
+		tt.setup()
+		day, got, err := p.Check(db, sectors, now)
+		if err != nil {
+			t.Fatalf("%s: Check returned an error: %v", tt.name, err)
+		}
+		if !day.Equal(tomorrow) || got != tt.want {
+			t.Errorf("%s: Check = %v, %v, want %v, %v", tt.name, day, got, tomorrow, tt.want)
+		}
+	}
+
+	// a restarted poller finds the stored prices, and waits for the next window
+	p = &PricePoller{}
+	if _, got, _ := p.Check(db, sectors, now); !got || !p.available.Equal(tomorrow) {
+		t.Errorf("after a restart: Check = %v, available %v, want true, %v", got, p.available, tomorrow)
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import "context"
+
+// PriceProvider is implemented by each of the sources we can get spot prices from
+type PriceProvider interface {
+	// Name returns the name of the provider, used when logging
+	Name() string
+	// GetPrices returns the hourly prices in øre/kWh for the sector, one result per day
+	GetPrices(ctx context.Context, numberOfDays int, sector string) ([]NorlysPricingResult, error)
+}
+
+// NewPriceProvider returns the price provider selected by Settings.PriceProvider
+func NewPriceProvider(settings *Settings) PriceProvider {
+	switch settings.PriceProvider {
+	case "energidataservice":
+		return &EnergiDataService{URL: settings.EnergiDataService.URL, HistoryURL: settings.EnergiDataService.HistoryURL, Retry: settings.EnergiDataService.Retry}
+	default:
+		return &NorlysAPI{URL: settings.NorlysAPI.URL, Retry: settings.NorlysAPI.Retry}
+	}
+}
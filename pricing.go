@@ -0,0 +1,83 @@
+package main
+
+import (
+	"time"
+	// embed the timezone database, as it's often missing in containers
+	_ "time/tzdata"
+)
+
+// danishTime is used to find the tariff position for an hour, as the positions are
+// hours of the day in danish time
+var danishTime = loadDanishTime()
+
+// loadDanishTime loads the Europe/Copenhagen timezone, the embedded timezone database is used
+// if the system doesn't have one, so falling back to local time shouldn't happen
+func loadDanishTime() *time.Location {
+	loc, err := time.LoadLocation("Europe/Copenhagen")
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// HourPrice is the all-in price for a single hour, all prices are in øre/kWh
+type HourPrice struct {
+	Hour           time.Time `json:"hour"`
+	Spot           float64   `json:"spot"`
+	GridTariff     float64   `json:"gridTariff"`
+	ElectricityTax float64   `json:"electricityTax"`
+	Energinet      float64   `json:"energinet"`
+	VAT            float64   `json:"vat"`
+	Total          float64   `json:"total"`
+}
+
+// PriceCalculator adds the grid tariffs, taxes and VAT to the spot price
+type PriceCalculator struct {
+	settings *Settings
+	tariffs  []StoredTariff
+}
+
+// NewPriceCalculator creates a PriceCalculator using the taxes from the settings, and the
+// provided tariffs, which may be empty if the meteringpoint isn't known
+func NewPriceCalculator(settings *Settings, tariffs []StoredTariff) *PriceCalculator {
+	return &PriceCalculator{settings: settings, tariffs: tariffs}
+}
+
+// Price calculates the all-in price for the hour, from the spot price in øre/kWh
+func (pc *PriceCalculator) Price(hour time.Time, spot float64) HourPrice {
+	p := HourPrice{
+		Hour:           hour,
+		Spot:           spot,
+		GridTariff:     pc.gridTariff(hour),
+		ElectricityTax: pc.settings.Pricing.ElectricityTax,
+		Energinet:      pc.settings.Pricing.EnerginetTariffs,
+	}
+
+	exVAT := p.Spot + p.GridTariff + p.ElectricityTax + p.Energinet
+	p.VAT = exVAT * pc.settings.Pricing.VATPercent / 100
+	p.Total = exVAT + p.VAT
+	return p
+}
+
+// gridTariff returns the sum of the tariffs valid at the hour in øre/kWh, tariffs with
+// a single price apply to every hour, otherwise the position is the hour of the day
+func (pc *PriceCalculator) gridTariff(hour time.Time) float64 {
+	position := hour.In(danishTime).Hour() + 1
+
+	var sum float64
+	for _, t := range pc.tariffs {
+		if hour.Before(t.ValidFromDate) || (t.ValidToDate.Valid && !hour.Before(t.ValidToDate.Time)) {
+			continue
+		}
+
+		// the tariffs are stored in DKK/kWh
+		if len(t.Prices) == 1 {
+			for _, price := range t.Prices {
+				sum += price * 100
+			}
+			continue
+		}
+		sum += t.Prices[position] * 100
+	}
+	return sum
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// requestTimeout is how long a single attempt towards an upstream API may take
+const requestTimeout = 20 * time.Second
+
+// RetryPolicy controls how requests towards an upstream API are retried, the backoff doubles
+// for each attempt, starting at InitialBackoff and capped at MaxBackoff, both in seconds
+type RetryPolicy struct {
+	MaxAttempts    int `toml:"MaxAttempts"`
+	InitialBackoff int `toml:"InitialBackoff"`
+	MaxBackoff     int `toml:"MaxBackoff"`
+}
+
+// withDefaults returns the policy, using the defaults for the fields that aren't configured
+func (p RetryPolicy) withDefaults(defaults RetryPolicy) RetryPolicy {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = defaults.MaxAttempts
+	}
+	if p.InitialBackoff == 0 {
+		p.InitialBackoff = defaults.InitialBackoff
+	}
+	if p.MaxBackoff == 0 {
+		p.MaxBackoff = defaults.MaxBackoff
+	}
+	return p
+}
+
+// validate checks the policy, name is used in the error message
+func (p RetryPolicy) validate(name string) []error {
+	var errs []error
+	if p.MaxAttempts < 1 {
+		errs = append(errs, errors.New(name+" retry MaxAttempts must be at least 1"))
+	}
+	if p.InitialBackoff < 1 || p.MaxBackoff < p.InitialBackoff {
+		errs = append(errs, errors.New(name+" retry InitialBackoff must be at least 1, and no more than MaxBackoff"))
+	}
+	return errs
+}
+
+// Backoff returns how long to wait after the failed attempt, attempts start at 1, the
+// result is between half and all of the exponential backoff, so clients don't retry in sync
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	max := time.Duration(p.MaxBackoff) * time.Second
+	d := time.Duration(p.InitialBackoff) * time.Second
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// Do makes the request returned by newRequest, and retries network errors, 429 and 5xx
+// responses, waiting the backoff or the time given by the Retry-After header, at most
+// MaxBackoff, between attempts
+// newRequest is called for each attempt, with a context that times out after requestTimeout,
+// the context is cancelled when the body of the returned response is closed
+func (p RetryPolicy) Do(ctx context.Context, newRequest func(ctx context.Context) (*http.Request, error)) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		attemptContext, cancelFunc := context.WithTimeout(ctx, requestTimeout)
+		req, err := newRequest(attemptContext)
+		if err != nil {
+			cancelFunc()
+			return nil, err
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err == nil && !retryableStatus(res.StatusCode) {
+			res.Body = cancelOnClose{ReadCloser: res.Body, cancel: cancelFunc}
+			return res, nil
+		}
+		if ctx.Err() != nil || attempt >= p.MaxAttempts {
+			if err != nil {
+				cancelFunc()
+				return nil, err
+			}
+			res.Body = cancelOnClose{ReadCloser: res.Body, cancel: cancelFunc}
+			return res, nil
+		}
+
+		wait := p.Backoff(attempt)
+		if err != nil {
+			log.Println("Request to", req.URL.Host, "failed, retrying in", wait.Round(time.Second), "-", err.Error())
+		} else {
+			if retryAfter, ok := parseRetryAfter(res.Header.Get("Retry-After")); ok {
+				wait = retryAfter
+				if max := time.Duration(p.MaxBackoff) * time.Second; wait > max {
+					wait = max
+				}
+			}
+			log.Println("Request to", req.URL.Host, "returned", res.Status+", retrying in", wait.Round(time.Second))
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+		cancelFunc()
+
+		if !sleepContext(ctx, wait) {
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// retryableStatus checks if the status code is caused by rate limiting or a temporary error
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusInternalServerError ||
+		code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+// parseRetryAfter parses the Retry-After header, which is either a number of seconds or a date
+func parseRetryAfter(header string) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// cancelOnClose cancels the context of the request when the response body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and cancels the context
+func (c cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDo(t *testing.T) {
+	tests := []struct {
+		name         string
+		responses    []int  // status codes of the attempts, the last is repeated
+		retryAfter   string // Retry-After header of the failed attempts
+		policy       RetryPolicy
+		wantStatus   int
+		wantAttempts int
+		minDuration  time.Duration
+		maxDuration  time.Duration
+	}{
+		{
+			name:      "success",
+			responses: []int{200}, policy: RetryPolicy{MaxAttempts: 3, InitialBackoff: 60, MaxBackoff: 60},
+			wantStatus: 200, wantAttempts: 1, maxDuration: time.Second,
+		},
+		{
+			name:      "client errors aren't retried",
+			responses: []int{400}, policy: RetryPolicy{MaxAttempts: 3, InitialBackoff: 60, MaxBackoff: 60},
+			wantStatus: 400, wantAttempts: 1, maxDuration: time.Second,
+		},
+		{
+			name:      "rate limited, Retry-After instead of the backoff",
+			responses: []int{429, 200}, retryAfter: "1", policy: RetryPolicy{MaxAttempts: 3, InitialBackoff: 60, MaxBackoff: 60},
+			wantStatus: 200, wantAttempts: 2, minDuration: time.Second, maxDuration: 5 * time.Second,
+		},
+		{
+			name:      "Retry-After longer than MaxBackoff",
+			responses: []int{429, 200}, retryAfter: "3600", policy: RetryPolicy{MaxAttempts: 3, InitialBackoff: 1, MaxBackoff: 1},
+			wantStatus: 200, wantAttempts: 2, minDuration: time.Second, maxDuration: 5 * time.Second,
+		},
+		{
+			name:      "Retry-After date later than MaxBackoff",
+			responses: []int{503, 200}, retryAfter: time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), policy: RetryPolicy{MaxAttempts: 3, InitialBackoff: 1, MaxBackoff: 1},
+			wantStatus: 200, wantAttempts: 2, minDuration: time.Second, maxDuration: 5 * time.Second,
+		},
+		{
+			name:      "server errors until the attempts are used",
+			responses: []int{503}, retryAfter: "0", policy: RetryPolicy{MaxAttempts: 3, InitialBackoff: 60, MaxBackoff: 60},
+			wantStatus: 503, wantAttempts: 3, maxDuration: time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.responses[len(tt.responses)-1]
+				if attempts < len(tt.responses) {
+					status = tt.responses[attempts]
+				}
+				attempts++
+				if status != 200 && tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(status)
+			}))
+			defer server.Close()
+
+			started := time.Now()
+			res, err := tt.policy.Do(context.Background(), func(ctx context.Context) (*http.Request, error) {
+				return http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+			})
+			elapsed := time.Since(started)
+			if err != nil {
+				t.Fatalf("Do returned an error: %v", err)
+			}
+			res.Body.Close()
+
+			if res.StatusCode != tt.wantStatus || attempts != tt.wantAttempts {
+				t.Errorf("got status %d after %d attempts, want %d after %d", res.StatusCode, attempts, tt.wantStatus, tt.wantAttempts)
+			}
+			if elapsed < tt.minDuration || elapsed > tt.maxDuration {
+				t.Errorf("took %v, want between %v and %v", elapsed, tt.minDuration, tt.maxDuration)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDoCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: 1, MaxBackoff: 1}
+	_, err := policy.Do(ctx, func(ctx context.Context) (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+		wantOk bool
+	}{
+		{header: "", wantOk: false},
+		{header: "0", want: 0, wantOk: true},
+		{header: "120", want: 2 * time.Minute, wantOk: true},
+		{header: "-1", wantOk: false},
+		{header: "soon", wantOk: false},
+		{header: "Wed, 21 Oct 2015 07:28:00 GMT", want: 0, wantOk: true}, // in the past
+	}
+
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.header)
+		if got != tt.want || ok != tt.wantOk {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.header, got, ok, tt.want, tt.wantOk)
+		}
+	}
+
+	// a date in the future is the time until then
+	got, ok := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	if !ok || got < 59*time.Minute || got > time.Hour {
+		t.Errorf("parseRetryAfter of a date in an hour = %v, %v", got, ok)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, InitialBackoff: 2, MaxBackoff: 30}
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{attempt: 1, max: 2 * time.Second},
+		{attempt: 2, max: 4 * time.Second},
+		{attempt: 4, max: 16 * time.Second},
+		{attempt: 5, max: 30 * time.Second},
+		{attempt: 9, max: 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			got := policy.Backoff(tt.attempt)
+			if got < tt.max/2 || got > tt.max {
+				t.Errorf("Backoff(%d) = %v, want between %v and %v", tt.attempt, got, tt.max/2, tt.max)
+			}
+		}
+	}
+}
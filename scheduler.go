@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// timeOfDay is a time of day in danish time, used by the daily schedules
+type timeOfDay struct {
+	Hour   int
+	Minute int
+}
+
+// parseTimeOfDay parses a time of day given as 13:15
+func parseTimeOfDay(value string) (timeOfDay, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return timeOfDay{}, err
+	}
+	return timeOfDay{Hour: t.Hour(), Minute: t.Minute()}, nil
+}
+
+// On returns the time of day on the danish date of day, plus days, time.Date handles the DST changes
+func (tod timeOfDay) On(day time.Time, days int) time.Time {
+	dk := day.In(danishTime)
+	return time.Date(dk.Year(), dk.Month(), dk.Day()+days, tod.Hour, tod.Minute, 0, 0, danishTime)
+}
+
+// minutes returns the number of minutes since midnight
+func (tod timeOfDay) minutes() int {
+	return tod.Hour*60 + tod.Minute
+}
+
+// Schedule decides when a collector runs, every Interval, at the Daily times, or whichever comes first
+// if both are set
+type Schedule struct {
+	Interval time.Duration
+	Daily    []timeOfDay // sorted
+}
+
+// ParseSchedule creates a schedule from the interval in seconds, and the daily times given as
+// "13:15 daily" or "06:00, 13:15 daily", the times are danish time, and daily may be left out
+func ParseSchedule(interval int, daily string) (Schedule, error) {
+	s := Schedule{Interval: time.Duration(interval) * time.Second}
+	if interval < 0 {
+		return s, errors.New("the interval can't be negative")
+	}
+
+	daily = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(daily), "daily"))
+	if daily == "" {
+		// without an interval or daily times the collector would never be scheduled
+		if interval == 0 {
+			return s, errors.New("an interval or times of day must be given, as 13:15 daily")
+		}
+		return s, nil
+	}
+	for _, value := range strings.Split(daily, ",") {
+		tod, err := parseTimeOfDay(value)
+		if err != nil {
+			return s, errors.New("the schedule must be times of day, as 13:15 daily, got: " + value)
+		}
+		s.Daily = append(s.Daily, tod)
+	}
+	sort.Slice(s.Daily, func(i, j int) bool {
+		return s.Daily[i].minutes() < s.Daily[j].minutes()
+	})
+	return s, nil
+}
+
+// Next returns when the collector should run after the run at last
+func (s Schedule) Next(last time.Time) time.Time {
+	var next time.Time
+	if s.Interval > 0 {
+		next = last.Add(s.Interval)
+	}
+
+	// the first daily time after last, today or tomorrow
+	for day := 0; day <= 1; day++ {
+		found := false
+		for _, tod := range s.Daily {
+			at := tod.On(last, day)
+			if at.After(last) {
+				if next.IsZero() || at.Before(next) {
+					next = at
+				}
+				found = true
+				break
+			}
+		}
+		if found {
+			break
+		}
+	}
+	return next
+}
+
+// Period returns the longest time between two runs, used to decide when a collector is stale
+func (s Schedule) Period() time.Duration {
+	if s.Interval > 0 || len(s.Daily) == 0 {
+		return s.Interval
+	}
+
+	// the gap from the last time of the day to the first time of the next day wraps around midnight
+	longest := 24*60 - s.Daily[len(s.Daily)-1].minutes() + s.Daily[0].minutes()
+	for i := 1; i < len(s.Daily); i++ {
+		gap := s.Daily[i].minutes() - s.Daily[i-1].minutes()
+		if gap > longest {
+			longest = gap
+		}
+	}
+	return time.Duration(longest) * time.Minute
+}
+
+// String describes the schedule for the log
+func (s Schedule) String() string {
+	parts := make([]string, 0, 2)
+	if s.Interval > 0 {
+		parts = append(parts, "every "+s.Interval.String())
+	}
+	if len(s.Daily) > 0 {
+		times := make([]string, 0, len(s.Daily))
+		for _, tod := range s.Daily {
+			times = append(times, fmt.Sprintf("%02d:%02d", tod.Hour, tod.Minute))
+		}
+		parts = append(parts, "daily at "+strings.Join(times, ", "))
+	}
+	return strings.Join(parts, " and ")
+}
+
+// Collector is a job run in the background by RunCollector
+type Collector struct {
+	Name     string
+	Schedule Schedule
+	Retry    RetryPolicy // the backoff used when Run fails
+	Run      func(ctx context.Context) error
+	// Next returns when to run after now, instead of the schedule, if set
+	Next func(now time.Time) time.Time
+}
+
+// RunCollector runs the collector right away, and then on its schedule, until ctx is cancelled
+// A failed run is retried with the backoff of the retry policy, unless the next scheduled run comes first
+func RunCollector(ctx context.Context, c Collector) {
+	next := c.Schedule.Next
+	if c.Next != nil {
+		next = c.Next
+	} else {
+		log.Println("Running the", c.Name, "collector", c.Schedule.String())
+	}
+
+	// failures is the number of times in a row we have failed, used for the backoff
+	failures := 0
+	for {
+		err := c.Run(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		wait := time.Until(next(time.Now()))
+		if err != nil {
+			failures++
+			if backoff := c.Retry.Backoff(failures); backoff < wait {
+				wait = backoff
+			}
+		} else {
+			failures = 0
+		}
+
+		// a schedule that doesn't give a time in the future must not make us hammer the upstream API
+		if wait <= 0 {
+			log.Println("The", c.Name, "collector has no upcoming run, waiting a minute")
+			wait = time.Minute
+		}
+
+		if !sleepContext(ctx, wait) {
+			return
+		}
+	}
+}
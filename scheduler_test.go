@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		interval int
+		daily    string
+		want     string // the String of the schedule
+		wantErr  bool
+	}{
+		{interval: 3600, daily: "", want: "every 1h0m0s"},
+		{interval: 0, daily: "13:15 daily", want: "daily at 13:15"},
+		{interval: 0, daily: "13:15", want: "daily at 13:15"},
+		{interval: 0, daily: " 13:15 , 6:00 daily ", want: "daily at 06:00, 13:15"},
+		{interval: 1800, daily: "13:15 daily", want: "every 30m0s and daily at 13:15"},
+		{interval: 0, daily: "", wantErr: true},
+		{interval: 0, daily: "daily", wantErr: true},
+		{interval: -1, daily: "13:15 daily", wantErr: true},
+		{interval: 0, daily: "1:15pm daily", wantErr: true},
+		{interval: 0, daily: "25:00 daily", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.interval, tt.daily)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSchedule(%d, %q) returned error %v, want error %v", tt.interval, tt.daily, err, tt.wantErr)
+			continue
+		}
+		if err == nil && s.String() != tt.want {
+			t.Errorf("ParseSchedule(%d, %q) = %s, want %s", tt.interval, tt.daily, s, tt.want)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	dk := func(month time.Month, day int, hour int, minute int) time.Time {
+		return time.Date(2025, month, day, hour, minute, 0, 0, danishTime)
+	}
+	tests := []struct {
+		name     string
+		interval int
+		daily    string
+		last     time.Time
+		want     time.Time
+	}{
+		{name: "interval", interval: 3600, last: dk(11, 10, 10, 30), want: dk(11, 10, 11, 30)},
+		{name: "later today", daily: "06:00, 13:15", last: dk(11, 10, 10, 30), want: dk(11, 10, 13, 15)},
+		{name: "exactly at a time", daily: "06:00, 13:15", last: dk(11, 10, 13, 15), want: dk(11, 11, 6, 0)},
+		{name: "tomorrow", daily: "06:00, 13:15", last: dk(11, 10, 20, 0), want: dk(11, 11, 6, 0)},
+		{name: "daily before interval", interval: 3600, daily: "13:15", last: dk(11, 10, 12, 30), want: dk(11, 10, 13, 15)},
+		{name: "interval before daily", interval: 3600, daily: "13:15", last: dk(11, 10, 10, 30), want: dk(11, 10, 11, 30)},
+		{name: "across the short DST day", daily: "13:15", last: dk(3, 29, 14, 0), want: dk(3, 30, 13, 15)},
+		{name: "across the long DST day", daily: "13:15", last: dk(10, 25, 14, 0), want: dk(10, 26, 13, 15)},
+		{name: "last in UTC", daily: "00:30", last: time.Date(2025, 11, 10, 23, 0, 0, 0, time.UTC), want: dk(11, 11, 0, 30)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseSchedule(tt.interval, tt.daily)
+			if err != nil {
+				t.Fatalf("ParseSchedule returned an error: %v", err)
+			}
+			if got := s.Next(tt.last); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.last, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchedulePeriod(t *testing.T) {
+	tests := []struct {
+		interval int
+		daily    string
+		want     time.Duration
+	}{
+		{interval: 3600, want: time.Hour},
+		{interval: 3600, daily: "13:15", want: time.Hour},
+		{daily: "13:15", want: 24 * time.Hour},
+		{daily: "06:00, 13:15", want: 16*time.Hour + 45*time.Minute},
+		{daily: "06:00, 08:00, 22:00", want: 14 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.interval, tt.daily)
+		if err != nil {
+			t.Fatalf("ParseSchedule(%d, %q) returned an error: %v", tt.interval, tt.daily, err)
+		}
+		if got := s.Period(); got != tt.want {
+			t.Errorf("Period of %s = %v, want %v", s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strconv"
+)
+
+// SectorForPostcode derives the price sector from a danish postcode, postcodes below 5000
+// are east of the Great Belt (DK2), the rest are west denmark (DK1)
+// An empty string is returned if the postcode isn't a valid danish postcode
+func SectorForPostcode(postcode string) string {
+	code, err := strconv.Atoi(postcode)
+	if err != nil || code < 1000 || code > 9999 {
+		return ""
+	}
+	if code < 5000 {
+		return "DK2"
+	}
+	return "DK1"
+}
+
+// SectorForMeteringPoint returns the price sector for the meteringpoint, a sector configured
+// in Settings.ElOverblik.MeteringPointSectors takes precedence, then the sector is derived
+// from the postcode, and if that isn't possible the first configured sector is used
+func SectorForMeteringPoint(settings *Settings, mp EloverblikMeteringPoint) string {
+	if sector, ok := settings.ElOverblik.MeteringPointSectors[mp.MeteringPointId]; ok {
+		return sector
+	}
+	if sector := SectorForPostcode(mp.Postcode); sector != "" {
+		return sector
+	}
+	return settings.NorlysAPI.Sectors[0]
+}
+
+// validSector checks if the sector is one of the danish price sectors
+func validSector(sector string) bool {
+	return sector == "DK1" || sector == "DK2"
+}
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 )
@@ -12,13 +13,19 @@ import (
 // settingsFile contains the configuration filename
 const filename = "lighthouse.toml"
 
+// configPath is the configuration file given by the --config flag
+var configPath string
+
 // Settings contains the entire configuration for the program
 type Settings struct {
-	SaveRequestTokenToDisk      bool `toml:"SaveRequestTokenToDisk"`
-	NumberOfDaysForPrices       int  `toml:"NumberOfDaysForPrices"`
-	NumberOfDaysForMeteringData int  `toml:"NumberOfDaysForMeteringData"`
-	APIPort                     int  `toml:"APIPort"`
+	SaveRequestTokenToDisk      bool   `toml:"SaveRequestTokenToDisk"`
+	NumberOfDaysForPrices       int    `toml:"NumberOfDaysForPrices"`
+	NumberOfDaysForMeteringData int    `toml:"NumberOfDaysForMeteringData"`
+	APIPort                     int    `toml:"APIPort"`
+	PriceProvider               string `toml:"PriceProvider"` // norlys or energidataservice
 	Database                    struct {
+		Driver   string `toml:"Driver"` // mysql or sqlite
+		Path     string `toml:"Path"`   // path to the database file, relative to the configuration file, only used by sqlite
 		Name     string `toml:"Name"`
 		HostName string `toml:"HostName"`
 		Username string `toml:"Username"`
@@ -26,71 +33,334 @@ type Settings struct {
 	} `toml:"Database"`
 	NorlysAPI struct {
 		URL                  string `toml:"URL"`
-		UpdatePricesInterval int    `toml:"UpdatePricesInterval"`
+		UpdatePricesInterval int    `toml:"UpdatePricesInterval"` // seconds between updates, defaults to 3600 unless Schedule is set
+		Schedule             string `toml:"Schedule"`             // update at these times, e.g. "13:15 daily" or "06:00, 13:15 daily"
+		DisableUpdates       bool   `toml:"DisableUpdates"`       // don't update the prices in the background, e.g. when lighthouse collect runs from cron
+		// the day-ahead prices are published around 13:00, so unless DisableSmartPolling is set, the
+		// prices are updated every PublicationPollInterval seconds during the PublicationWindow, until
+		// tomorrow's prices are available, and then not until the next day, the schedule is only used
+		// if the prices are late
+		DisableSmartPolling     bool        `toml:"DisableSmartPolling"`
+		PublicationWindow       string      `toml:"PublicationWindow"`       // danish time, defaults to 12:45-15:00
+		PublicationPollInterval int         `toml:"PublicationPollInterval"` // defaults to 300
+		Sectors                 []string    `toml:"Sectors"`                 // DK1 and/or DK2, defaults to DK1
+		Retry                   RetryPolicy `toml:"Retry"`
 	} `toml:"NorlysAPI"`
+	EnergiDataService struct {
+		URL        string      `toml:"URL"`        // the DayAheadPrices dataset
+		HistoryURL string      `toml:"HistoryURL"` // the Elspotprices dataset, used for the prices before 2025-10-01
+		Retry      RetryPolicy `toml:"Retry"`
+	} `toml:"EnergiDataService"`
+	Pricing struct {
+		ElectricityTax   float64 `toml:"ElectricityTax"`   // elafgift in øre/kWh excluding VAT
+		EnerginetTariffs float64 `toml:"EnerginetTariffs"` // Energinet net and system tariffs in øre/kWh excluding VAT
+		VATPercent       float64 `toml:"VATPercent"`       // moms, defaults to 25
+	} `toml:"Pricing"`
 	ElOverblik struct {
 		FetchDataFromElOverblik bool   `toml:"FetchDataFromElOverblik"`
-		FetchDataInterval       int    `toml:"FetchDataInterval"`
+		FetchDataInterval       int    `toml:"FetchDataInterval"` // seconds between fetches, defaults to 3600 unless Schedule is set
+		Schedule                string `toml:"Schedule"`          // fetch at these times, e.g. "06:00 daily"
 		LighthouseToken         string `toml:"LighthouseToken"`
+		// MeteringPointSectors maps meteringPointId to sector, for meteringpoints where the
+		// sector can't be derived from the postcode
+		MeteringPointSectors map[string]string `toml:"MeteringPointSectors"`
+		Retry                RetryPolicy       `toml:"Retry"`             // eloverblik rate limits aggressively, so the defaults backs off longer
+		SyncOverlapHours     int               `toml:"SyncOverlapHours"`  // refetch this many hours before the last synced hour, to get late corrections, defaults to 72
+		MaxDaysPerRequest    int               `toml:"MaxDaysPerRequest"` // the longest period requested at once, eloverblik allows at most 730 days
 	} `toml:"ElOverblik"`
+	TLS struct {
+		Enabled      bool     `toml:"Enabled"`
+		CertFile     string   `toml:"CertFile"`
+		KeyFile      string   `toml:"KeyFile"`
+		SelfSigned   bool     `toml:"SelfSigned"`   // generate a self-signed certificate, if CertFile and KeyFile doesn't exist
+		Hosts        []string `toml:"Hosts"`        // hostnames and IPs for the self-signed certificate
+		RedirectHTTP bool     `toml:"RedirectHTTP"` // redirect plain HTTP requests on HTTPPort to HTTPS
+		HTTPPort     int      `toml:"HTTPPort"`     // defaults to 80
+	} `toml:"TLS"`
+	Tenants []Tenant `toml:"Tenants"`
+	Auth    struct {
+		Enabled       bool   `toml:"Enabled"`
+		JWTSecret     string `toml:"JWTSecret"`    // used to sign session tokens, at least 32 characters
+		SessionHours  int    `toml:"SessionHours"` // how long a login lasts, defaults to 24
+		AdminUsername string `toml:"AdminUsername"`
+		AdminPassword string `toml:"AdminPassword"` // only used to create the admin user when it doesn't exist, later changes are made through the API
+	} `toml:"Auth"`
+	Notifications struct {
+		Targets []NotificationTarget `toml:"Targets"`
+		Rules   []NotificationRule   `toml:"Rules"`
+	} `toml:"Notifications"`
+
+	// ConfigFile is the configuration file the settings were read from, empty if they only
+	// came from environment variables
+	ConfigFile string `toml:"-"`
 }
 
-// ReadConfigurationFile find the configuration file on disk an parses it
+// ReadConfigurationFile finds the configuration file and parses it, applies the LIGHTHOUSE_*
+// environment variables, and validates the result, the error lists every invalid field
 func (s *Settings) ReadConfigurationFile() error {
-
-	// find the directory of the application
-	dir, err := filepath.Abs(filepath.Dir(os.Args[0]))
+	err := s.Load()
 	if err != nil {
 		return err
 	}
-	if !fileExists(filepath.Join(dir, filename)) {
-		return errors.New("error reading configuration file, file: " + filename + " does not exists")
+
+	errs := s.Validate()
+	if len(errs) > 0 {
+		return ConfigErrors(errs)
 	}
+	return nil
+}
 
-	// Read the configuration file into mem
-	file, err := os.Open(filepath.Join(dir, filename))
-	if err != nil {
-		return errors.New("error opening configuration file, file: " + err.Error())
+// Load reads the configuration file and applies the LIGHTHOUSE_* environment variables, without
+// validating the settings
+// The file is given by --config or LIGHTHOUSE_CONFIG, otherwise the search path is used, and if
+// no file is found the settings can be given by environment variables alone
+func (s *Settings) Load() error {
+	path := configPath
+	if path == "" {
+		path = os.Getenv(envConfigFile)
 	}
-	defer file.Close()
-	tomlData, err := ioutil.ReadAll(file)
-	if err != nil {
-		return errors.New("ERROR reading configuration file, file: " + err.Error())
+	if path != "" {
+		if !fileExists(path) {
+			return errors.New("error reading configuration file, file: " + path + " does not exists")
+		}
+	} else {
+		for _, p := range configSearchPath() {
+			if fileExists(p) {
+				path = p
+				break
+			}
+		}
 	}
+	if path == "" && !hasEnvOverrides() {
+		return errors.New("error reading configuration file, " + filename + " not found in: " + strings.Join(configSearchPath(), ", "))
+	}
+
+	if path != "" {
+		// Read the configuration file into mem
+		file, err := os.Open(path)
+		if err != nil {
+			return errors.New("error opening configuration file, file: " + err.Error())
+		}
+		defer file.Close()
+		tomlData, err := ioutil.ReadAll(file)
+		if err != nil {
+			return errors.New("ERROR reading configuration file, file: " + err.Error())
+		}
+
+		if _, err := toml.Decode(string(tomlData), s); err != nil {
+			return errors.New("ERROR decoding toml data in " + path + ": " + err.Error())
+		}
+		s.ConfigFile = path
+	}
+
+	errs := applyEnvOverrides(s)
+	if len(errs) > 0 {
+		return ConfigErrors(errs)
+	}
+	return nil
+}
 
-	if _, err := toml.Decode(string(tomlData), &s); err != nil {
-		return errors.New("ERROR decoding toml data:" + err.Error())
+// DataDir returns the directory the relative paths in the settings are relative to, which is
+// the directory of the configuration file, or the working directory if the settings only came
+// from environment variables
+func (s *Settings) DataDir() string {
+	dir := "."
+	if s.ConfigFile != "" {
+		dir = filepath.Dir(s.ConfigFile)
 	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return dir
+}
+
+// configSearchPath returns the paths searched for the configuration file, in order: the
+// working directory, the directory of the application, the XDG config directories and /etc/lighthouse
+// The application directory comes after the working directory, as it's a temporary directory under go run
+func configSearchPath() []string {
+	paths := make([]string, 0)
+	if dir, err := os.Getwd(); err == nil {
+		paths = append(paths, filepath.Join(dir, filename))
+	}
+	if dir, err := filepath.Abs(filepath.Dir(os.Args[0])); err == nil {
+		paths = append(paths, filepath.Join(dir, filename))
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, "lighthouse", filename))
+	}
+	configDirs := os.Getenv("XDG_CONFIG_DIRS")
+	if configDirs == "" {
+		configDirs = "/etc/xdg"
+	}
+	for _, dir := range filepath.SplitList(configDirs) {
+		paths = append(paths, filepath.Join(dir, "lighthouse", filename))
+	}
+	paths = append(paths, filepath.Join("/etc/lighthouse", filename))
+
+	// the application directory and the working directory are often the same
+	res := make([]string, 0, len(paths))
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		if !seen[p] {
+			res = append(res, p)
+			seen[p] = true
+		}
+	}
+	return res
+}
+
+// ConfigErrors is returned when the settings are invalid, it holds an error per invalid field
+type ConfigErrors []error
+
+// Error lists the errors, one per line
+func (ce ConfigErrors) Error() string {
+	lines := make([]string, 0, len(ce))
+	for _, err := range ce {
+		lines = append(lines, err.Error())
+	}
+	return "invalid configuration:\n  " + strings.Join(lines, "\n  ")
+}
+
+// Validate checks the settings and applies the defaults, it returns an error for each invalid field
+func (s *Settings) Validate() []error {
+	errs := make([]error, 0)
 
 	// Check if all the critical fields are configured correctly
-	if s.Database.Name == "" {
-		return errors.New("database name not configured")
+	if s.Database.Driver == "" {
+		s.Database.Driver = "mysql"
+	}
+	switch s.Database.Driver {
+	case "mysql":
+		if s.Database.Name == "" {
+			errs = append(errs, errors.New("database name not configured"))
+		}
+		if s.Database.Password == "" {
+			errs = append(errs, errors.New("database password not configured"))
+		}
+		if s.Database.Username == "" {
+			errs = append(errs, errors.New("database username not configured"))
+		}
+		if s.Database.HostName == "" {
+			errs = append(errs, errors.New("database hostname not configured"))
+		}
+	case "sqlite":
+		if s.Database.Path == "" {
+			s.Database.Path = "lighthouse.db"
+		}
+	default:
+		errs = append(errs, errors.New("database driver must be mysql or sqlite, got: "+s.Database.Driver))
+	}
+	if s.PriceProvider == "" {
+		s.PriceProvider = "norlys"
+	}
+	switch s.PriceProvider {
+	case "norlys":
+		if s.NorlysAPI.URL == "" {
+			errs = append(errs, errors.New("norlys url not configured"))
+		}
+	case "energidataservice":
+	default:
+		errs = append(errs, errors.New("price provider must be norlys or energidataservice, got: "+s.PriceProvider))
+	}
+
+	// energi data service is also used for backfilling prices, whatever the price provider is
+	if s.EnergiDataService.URL == "" {
+		s.EnergiDataService.URL = "https://api.energidataservice.dk/dataset/DayAheadPrices"
+	}
+	if strings.HasSuffix(strings.TrimSuffix(s.EnergiDataService.URL, "/"), "/Elspotprices") {
+		errs = append(errs, errors.New("energidataservice URL must be the DayAheadPrices dataset, Elspotprices isn't updated since 2025-10-01, it's used for older prices by HistoryURL"))
+	}
+	if s.EnergiDataService.HistoryURL == "" {
+		s.EnergiDataService.HistoryURL = "https://api.energidataservice.dk/dataset/Elspotprices"
+	}
+
+	if len(s.NorlysAPI.Sectors) == 0 {
+		s.NorlysAPI.Sectors = []string{"DK1"}
+	}
+	for _, sector := range s.NorlysAPI.Sectors {
+		if !validSector(sector) {
+			errs = append(errs, errors.New("norlys sector must be DK1 or DK2, got: "+sector))
+		}
+	}
+	for mpId, sector := range s.ElOverblik.MeteringPointSectors {
+		if !validSector(sector) {
+			errs = append(errs, errors.New("sector for meteringpoint "+mpId+" must be DK1 or DK2, got: "+sector))
+		}
+	}
+
+	s.NorlysAPI.Retry = s.NorlysAPI.Retry.withDefaults(RetryPolicy{MaxAttempts: 4, InitialBackoff: 5, MaxBackoff: 300})
+	errs = append(errs, s.NorlysAPI.Retry.validate("norlys")...)
+	s.EnergiDataService.Retry = s.EnergiDataService.Retry.withDefaults(RetryPolicy{MaxAttempts: 4, InitialBackoff: 5, MaxBackoff: 300})
+	errs = append(errs, s.EnergiDataService.Retry.validate("energidataservice")...)
+	s.ElOverblik.Retry = s.ElOverblik.Retry.withDefaults(RetryPolicy{MaxAttempts: 5, InitialBackoff: 30, MaxBackoff: 1800})
+	errs = append(errs, s.ElOverblik.Retry.validate("eloverblik")...)
+
+	if s.ElOverblik.SyncOverlapHours == 0 {
+		s.ElOverblik.SyncOverlapHours = 72
 	}
-	if s.Database.Password == "" {
-		return errors.New("database password not configured")
+	if s.ElOverblik.SyncOverlapHours < 0 {
+		errs = append(errs, errors.New("eloverblik SyncOverlapHours can't be negative"))
 	}
-	if s.Database.Username == "" {
-		return errors.New("database username not configured")
+	if s.ElOverblik.MaxDaysPerRequest == 0 {
+		s.ElOverblik.MaxDaysPerRequest = 730
 	}
-	if s.Database.HostName == "" {
-		return errors.New("database hostname not configured")
+	if s.ElOverblik.MaxDaysPerRequest < 1 || s.ElOverblik.MaxDaysPerRequest > 730 {
+		errs = append(errs, errors.New("eloverblik MaxDaysPerRequest must be between 1 and 730"))
 	}
-	if s.NorlysAPI.URL == "" {
-		return errors.New("norlys url not configured")
+
+	errs = append(errs, validateTLS(s)...)
+	errs = append(errs, validateTenants(s)...)
+	errs = append(errs, validateAuth(s)...)
+	errs = append(errs, validateNotifications(s)...)
+
+	if s.Pricing.VATPercent == 0 {
+		s.Pricing.VATPercent = 25
 	}
 
-	if s.NorlysAPI.UpdatePricesInterval == 0 {
+	if s.NorlysAPI.UpdatePricesInterval == 0 && strings.TrimSpace(s.NorlysAPI.Schedule) == "" {
 		s.NorlysAPI.UpdatePricesInterval = 3600
 	}
+	if _, err := s.PricesSchedule(); err != nil {
+		errs = append(errs, errors.New("norlys schedule: "+err.Error()))
+	}
+	if s.NorlysAPI.PublicationWindow == "" {
+		s.NorlysAPI.PublicationWindow = "12:45-15:00"
+	}
+	if _, _, err := parsePublicationWindow(s.NorlysAPI.PublicationWindow); err != nil {
+		errs = append(errs, errors.New("norlys PublicationWindow "+err.Error()))
+	}
+	if s.NorlysAPI.PublicationPollInterval == 0 {
+		s.NorlysAPI.PublicationPollInterval = 300
+	}
+	if s.NorlysAPI.PublicationPollInterval < 0 {
+		errs = append(errs, errors.New("norlys PublicationPollInterval can't be negative"))
+	}
+	if s.ElOverblik.FetchDataInterval == 0 && strings.TrimSpace(s.ElOverblik.Schedule) == "" {
+		s.ElOverblik.FetchDataInterval = 3600
+	}
+	if _, err := s.EloverblikSchedule(); err != nil {
+		errs = append(errs, errors.New("eloverblik schedule: "+err.Error()))
+	}
 
-	return nil
+	return errs
+}
+
+// PricesSchedule returns when the prices are updated
+func (s *Settings) PricesSchedule() (Schedule, error) {
+	return ParseSchedule(s.NorlysAPI.UpdatePricesInterval, s.NorlysAPI.Schedule)
+}
+
+// EloverblikSchedule returns when the eloverblik data is fetched
+func (s *Settings) EloverblikSchedule() (Schedule, error) {
+	return ParseSchedule(s.ElOverblik.FetchDataInterval, s.ElOverblik.Schedule)
 }
 
 // fileExists checks if a file exists and is not a directory before we
 // try using it to prevent further errors.
 func fileExists(filename string) bool {
+	// any error, e.g. a search path below a regular file or without permission, means we can't use it
 	info, err := os.Stat(filename)
-	if os.IsNotExist(err) {
+	if err != nil {
 		return false
 	}
 	return !info.IsDir()
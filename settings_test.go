@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSqlitePath(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("unable to get working directory: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		configFile string
+		path       string
+		want       string
+	}{
+		{name: "relative to the configuration file", configFile: "/etc/lighthouse/lighthouse.toml", path: "lighthouse.db", want: "/etc/lighthouse/lighthouse.db"},
+		{name: "relative to a relative configuration file", configFile: "conf/lighthouse.toml", path: "data/lighthouse.db", want: filepath.Join(wd, "conf/data/lighthouse.db")},
+		{name: "relative to the working directory without a configuration file", path: "lighthouse.db", want: filepath.Join(wd, "lighthouse.db")},
+		{name: "absolute", configFile: "/etc/lighthouse/lighthouse.toml", path: "/var/lib/lighthouse/lighthouse.db", want: "/var/lib/lighthouse/lighthouse.db"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := &Settings{ConfigFile: tt.configFile}
+			settings.Database.Path = tt.path
+			got, err := sqlitePath(settings)
+			if err != nil {
+				t.Fatalf("sqlitePath returned an error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDataDirOfLoadedSettings(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lighthouse.toml")
+	if err := os.WriteFile(path, []byte("[Database]\nDriver = \"sqlite\"\n"), 0600); err != nil {
+		t.Fatalf("unable to write configuration file: %v", err)
+	}
+	configPath = path
+	defer func() { configPath = "" }()
+
+	settings := &Settings{}
+	if err := settings.Load(); err != nil {
+		t.Fatalf("Load returned an error: %v", err)
+	}
+	if settings.DataDir() != dir {
+		t.Errorf("got data directory %s, want %s", settings.DataDir(), dir)
+	}
+
+	// the request token is saved next to the configuration file
+	eo := &ElOverblik{Tenant: "home", TokenDir: settings.DataDir()}
+	if err := eo.SaveRequestTokenToDisk(`{"token": "x"}`); err != nil {
+		t.Fatalf("SaveRequestTokenToDisk returned an error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".requestToken-home")); err != nil {
+		t.Errorf("the request token wasn't saved in the data directory: %v", err)
+	}
+	token, found, err := eo.ReadRequestTokenFromDisk()
+	if err != nil || !found || string(token) != `{"token": "x"}` {
+		t.Errorf("ReadRequestTokenFromDisk = %s, %v, %v", token, found, err)
+	}
+}
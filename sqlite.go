@@ -0,0 +1,48 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"path/filepath"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SQLiteDatabase stores the data in a local SQLite file, the queries are shared with
+// the MySQL Database, so only connecting and migrating the schema is handled here
+type SQLiteDatabase struct {
+	Database
+}
+
+// ConnectToDatabase opens the SQLite file, and applies the schema migrations
+func (db *SQLiteDatabase) ConnectToDatabase(settings *Settings) error {
+	path, err := sqlitePath(settings)
+	if err != nil {
+		return err
+	}
+
+	db.handle, err = sql.Open("sqlite3", "file:"+path+"?_busy_timeout=5000&_foreign_keys=on")
+	if err != nil {
+		log.Println("ERROR opening sqlite database:", err.Error())
+		return err
+	}
+
+	// SQLite only allows a single writer, so let's not have the collectors fight over it
+	db.handle.SetMaxOpenConns(1)
+
+	return db.Migrate()
+}
+
+// Migrate applies the SQLite schema migrations
+func (db *SQLiteDatabase) Migrate() error {
+	return runMigrations(db.handle, "sqlite")
+}
+
+// sqlitePath returns the path to the SQLite file, relative paths are relative to
+// Settings.DataDir
+func sqlitePath(settings *Settings) (string, error) {
+	if filepath.IsAbs(settings.Database.Path) {
+		return settings.Database.Path, nil
+	}
+	return filepath.Join(settings.DataDir(), settings.Database.Path), nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Store is implemented by each of the supported storage backends
+type Store interface {
+	// Migrate applies the schema migrations that hasn't been applied yet
+	Migrate() error
+	// Ping checks that the storage backend is reachable
+	Ping(ctx context.Context) error
+	// Close closes the connection to the storage backend
+	Close() error
+
+	// SaveNorlysPricingResult saves the norlys pricedata
+	SaveNorlysPricingResult(pd *NorlysPricingResult) error
+	// SaveMeteringPoints saves each of the provided meteringpoints, and associates them with the tenant
+	SaveMeteringPoints(tenant string, mps *[]EloverblikMeteringPoint) error
+	// SaveMeteringTimeSeries saves each entry in the timeSeries slice
+	SaveMeteringTimeSeries(mts EloverblikMeteringTimeSeriesResult) error
+	// SaveCharges saves the tariffs, subscriptions and fees for a meteringpoint
+	SaveCharges(charges EloverblikCharges) error
+
+	// GetMeteringPoints returns the stored meteringpoints belonging to the tenants, or all of them if tenants is nil
+	GetMeteringPoints(tenants []string) ([]MeteringPointSummary, error)
+	// GetMeteringPointTenants returns the tenants the meteringpoint belongs to
+	GetMeteringPointTenants(meteringPointId string) ([]string, error)
+	// MeteringPointExists checks if the provided meteringpoint is stored
+	MeteringPointExists(meteringPointId string) (bool, error)
+	// GetMeteringPointSector returns the price sector stored for the meteringpoint
+	GetMeteringPointSector(meteringPointId string) (string, error)
+	// GetMeteringTimeSeries returns the hourly readings for the meteringpoint, where from <= hour < to
+	GetMeteringTimeSeries(meteringPointId string, from time.Time, to time.Time) ([]MeteringTimeSeriesEntry, error)
+	// GetSpotPrices returns the stored prices for the sector, where from <= hour < to
+	GetSpotPrices(sector string, from time.Time, to time.Time) ([]SpotPrice, error)
+	// GetTariffs returns the tariffs for the meteringpoint that are valid at some point between from and to
+	GetTariffs(meteringPointId string, from time.Time, to time.Time) ([]StoredTariff, error)
+	// GetMeteringHighWaterMark returns the latest hour synced from eloverblik for the meteringpoint, found is false if it hasn't been synced
+	GetMeteringHighWaterMark(meteringPointId string) (lastHour time.Time, found bool, err error)
+	// SaveMeteringHighWaterMark stores the latest hour synced from eloverblik for the meteringpoint
+	SaveMeteringHighWaterMark(meteringPointId string, lastHour time.Time) error
+	// GetBackfillProgress returns how far the backfills of the source and target starting at from have come, with any end date, found is false if none has started
+	GetBackfillProgress(source string, target string, from time.Time) (doneUntil time.Time, found bool, err error)
+	// SaveBackfillProgress stores how far the backfill of the source and target between from and to has come
+	SaveBackfillProgress(source string, target string, from time.Time, to time.Time, doneUntil time.Time) error
+	// GetLatestMeteringReadings returns the latest stored hourly reading for each meteringpoint
+	GetLatestMeteringReadings() ([]MeteringTimeSeriesEntry, error)
+	// GetHourlyCost returns the hourly readings for the meteringpoint joined with the price for each hour
+	GetHourlyCost(meteringPointId string, sector string, from time.Time, to time.Time) ([]HourlyCostEntry, error)
+
+	// CountUsers returns the number of user accounts
+	CountUsers() (int, error)
+	// CreateUser creates a user account, and returns the id of the user
+	CreateUser(username string, passwordHash string) (int64, error)
+	// GetUserByUsername returns the user with the username
+	GetUserByUsername(username string) (user User, found bool, err error)
+	// GetUsers returns every user account
+	GetUsers() ([]User, error)
+	// SetUserPassword replaces the password hash of the user
+	SetUserPassword(username string, passwordHash string) (updated bool, err error)
+	// DeleteUser deletes the user and the API keys of the user
+	DeleteUser(username string) (deleted bool, err error)
+	// GetUserByAPIKeyHash returns the user owning the API key
+	GetUserByAPIKeyHash(keyHash string) (user User, found bool, err error)
+	// CreateAPIKey stores the hash of a new API key for the user, and returns the id of the key
+	CreateAPIKey(userId int64, name string, keyHash string) (int64, error)
+	// GetAPIKeys returns the API keys of the user
+	GetAPIKeys(userId int64) ([]APIKey, error)
+	// DeleteAPIKey deletes the API key of the user
+	DeleteAPIKey(userId int64, id int64) (deleted bool, err error)
+}
+
+// NewStore connects to the storage backend selected by Settings.Database.Driver
+func NewStore(settings *Settings) (Store, error) {
+	switch settings.Database.Driver {
+	case "mysql":
+		db := &Database{}
+		err := db.ConnectToDatabase(settings)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	case "sqlite":
+		db := &SQLiteDatabase{}
+		err := db.ConnectToDatabase(settings)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	}
+	return nil, errors.New("unknown database driver: " + settings.Database.Driver)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+)
+
+// defaultTenant is the name of the tenant created from Settings.ElOverblik.LighthouseToken,
+// when no tenants are configured
+const defaultTenant = "default"
+
+// validTenantName matches the allowed tenant names, the name is part of the filename of the
+// request token, so it can't contain path separators or dots
+var validTenantName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Tenant is a household with its own eloverblik account, the users listed can see
+// the meteringpoints of the tenant through the API
+type Tenant struct {
+	Name            string   `toml:"Name"`
+	LighthouseToken string   `toml:"LighthouseToken"`
+	Users           []string `toml:"Users"`
+}
+
+// TenantList returns the configured tenants, or the default tenant using the eloverblik
+// token, if no tenants are configured
+func (s *Settings) TenantList() []Tenant {
+	if len(s.Tenants) > 0 {
+		return s.Tenants
+	}
+	if s.ElOverblik.LighthouseToken == "" {
+		return []Tenant{}
+	}
+	return []Tenant{{Name: defaultTenant, LighthouseToken: s.ElOverblik.LighthouseToken}}
+}
+
+// TenantsForUser returns the names of the tenants the user can see
+func (s *Settings) TenantsForUser(username string) []string {
+	res := make([]string, 0)
+	for _, t := range s.TenantList() {
+		for _, u := range t.Users {
+			if u == username {
+				res = append(res, t.Name)
+				break
+			}
+		}
+	}
+	return res
+}
+
+// validateTenants checks the tenant settings
+func validateTenants(s *Settings) []error {
+	var errs []error
+	names := make(map[string]bool)
+	for _, t := range s.Tenants {
+		if t.Name == "" {
+			errs = append(errs, errors.New("tenant name not configured"))
+		} else if !validTenantName.MatchString(t.Name) {
+			errs = append(errs, errors.New("tenant name can only contain letters, digits, _ and -, got: "+t.Name))
+		}
+		if names[t.Name] {
+			errs = append(errs, errors.New("tenant "+t.Name+" is configured more than once"))
+		}
+		if t.LighthouseToken == "" {
+			errs = append(errs, errors.New("tenant "+t.Name+" LighthouseToken not configured"))
+		}
+		names[t.Name] = true
+	}
+	return errs
+}
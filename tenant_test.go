@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateTenants(t *testing.T) {
+	tests := []struct {
+		name     string
+		tenants  []Tenant
+		wantErrs []string // a part of each of the errors
+	}{
+		{name: "valid", tenants: []Tenant{{Name: "home", LighthouseToken: "a"}, {Name: "Summer_House-2", LighthouseToken: "b"}}},
+		{name: "no name", tenants: []Tenant{{LighthouseToken: "a"}}, wantErrs: []string{"tenant name not configured"}},
+		{name: "duplicate", tenants: []Tenant{{Name: "home", LighthouseToken: "a"}, {Name: "home", LighthouseToken: "b"}}, wantErrs: []string{"configured more than once"}},
+		{name: "no token", tenants: []Tenant{{Name: "home"}}, wantErrs: []string{"LighthouseToken not configured"}},
+		{name: "parent directory", tenants: []Tenant{{Name: "../x", LighthouseToken: "a"}}, wantErrs: []string{"can only contain"}},
+		{name: "path separator", tenants: []Tenant{{Name: "a/b", LighthouseToken: "a"}}, wantErrs: []string{"can only contain"}},
+		{name: "dot", tenants: []Tenant{{Name: ".", LighthouseToken: "a"}}, wantErrs: []string{"can only contain"}},
+		{name: "space", tenants: []Tenant{{Name: "my home", LighthouseToken: "a"}}, wantErrs: []string{"can only contain"}},
+		{name: "non ascii", tenants: []Tenant{{Name: "sommerhus-æ", LighthouseToken: "a"}}, wantErrs: []string{"can only contain"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Settings{Tenants: tt.tenants}
+			errs := validateTenants(s)
+			if len(errs) != len(tt.wantErrs) {
+				t.Fatalf("got errors %v, want %v", errs, tt.wantErrs)
+			}
+			for i := range errs {
+				if !strings.Contains(errs[i].Error(), tt.wantErrs[i]) {
+					t.Errorf("got error %q, want %q", errs[i], tt.wantErrs[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// CertReloader holds the TLS certificate, and reloads it from disk on SIGHUP, so renewed
+// certificates can be used without restarting
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	lock sync.RWMutex
+	cert *tls.Certificate
+}
+
+// NewCertReloader loads the certificate from the files in the settings, if SelfSigned is
+// enabled and the files doesn't exist, a self-signed certificate is generated first
+func NewCertReloader(settings *Settings) (*CertReloader, error) {
+	cr := &CertReloader{
+		certFile: settings.TLS.CertFile,
+		keyFile:  settings.TLS.KeyFile,
+	}
+
+	if settings.TLS.SelfSigned && !fileExists(cr.certFile) && !fileExists(cr.keyFile) {
+		log.Println("Generating self-signed certificate", cr.certFile)
+		err := generateSelfSignedCert(cr.certFile, cr.keyFile, settings.TLS.Hosts)
+		if err != nil {
+			return nil, errors.New("unable to generate self-signed certificate: " + err.Error())
+		}
+	}
+
+	err := cr.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// Reload reads the certificate and key from disk, the current certificate is kept if it fails
+func (cr *CertReloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return errors.New("unable to load certificate: " + err.Error())
+	}
+
+	cr.lock.Lock()
+	cr.cert = &cert
+	cr.lock.Unlock()
+	return nil
+}
+
+// GetCertificate is used as tls.Config.GetCertificate, and returns the current certificate
+func (cr *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.lock.RLock()
+	defer cr.lock.RUnlock()
+	return cr.cert, nil
+}
+
+// ReloadOnSIGHUP reloads the certificate every time the process receives SIGHUP
+func (cr *CertReloader) ReloadOnSIGHUP() {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for range sighup {
+			log.Println("Reloading TLS certificate")
+			err := cr.Reload()
+			if err != nil {
+				log.Println("Error reloading TLS certificate, keeping the current one:", err.Error())
+			}
+		}
+	}()
+}
+
+// TLSConfig returns a TLS config using the reloadable certificate
+func (cr *CertReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: cr.GetCertificate,
+	}
+}
+
+// generateSelfSignedCert creates a self-signed certificate valid for the hosts, which may
+// be hostnames or IP addresses, and writes the certificate and key as PEM files
+func generateSelfSignedCert(certFile string, keyFile string, hosts []string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Lighthouse"}, CommonName: "lighthouse"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(2, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	if len(hosts) == 0 {
+		hosts = []string{"localhost", "127.0.0.1"}
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(certFile), 0755)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(keyFile), 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+}
+
+// NewHTTPSRedirectServer creates a plain HTTP server on httpPort, that redirects every
+// request to the same host and path on the HTTPS port
+func NewHTTPSRedirectServer(httpPort int, httpsPort int) *http.Server {
+	return &http.Server{
+		Addr:              ":" + strconv.Itoa(httpPort),
+		ReadHeaderTimeout: 10 * time.Second,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host, _, err := net.SplitHostPort(r.Host)
+			if err != nil {
+				host = r.Host
+			}
+			if httpsPort != 443 {
+				host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+			}
+			http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+		}),
+	}
+}
+
+// validateTLS checks the TLS settings
+func validateTLS(s *Settings) []error {
+	var errs []error
+	if !s.TLS.Enabled {
+		return nil
+	}
+	if s.TLS.CertFile == "" || s.TLS.KeyFile == "" {
+		errs = append(errs, errors.New("tls CertFile and KeyFile must be configured"))
+	}
+	if s.TLS.RedirectHTTP && s.TLS.HTTPPort == 0 {
+		s.TLS.HTTPPort = 80
+	}
+	if s.TLS.RedirectHTTP && s.TLS.HTTPPort == s.APIPort {
+		errs = append(errs, errors.New("tls HTTPPort must be different from APIPort"))
+	}
+	return errs
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"embed"
+
+	"github.com/labstack/echo/v4"
+)
+
+// webFiles contains the static files for the web dashboard
+//
+//go:embed web
+var webFiles embed.FS
+
+// RegisterWebUI serves the web dashboard from the root of the echo instance
+func RegisterWebUI(e *echo.Echo) {
+	e.StaticFS("/", echo.MustSubFS(webFiles, "web"))
+}
@@ -0,0 +1,155 @@
+// Lighthouse dashboard, all data comes from the JSON API served next to it
+"use strict";
+
+const meteringPointSelect = document.getElementById("meteringPoint");
+
+// showLogin replaces the dashboard with the login form, when authentication is required
+function showLogin() {
+  document.querySelector("main").hidden = true;
+  document.getElementById("login").hidden = false;
+}
+
+document.getElementById("login").addEventListener("submit", async (event) => {
+  event.preventDefault();
+  const res = await fetch("login", { method: "POST", body: new URLSearchParams(new FormData(event.target)) });
+  if (!res.ok) {
+    document.getElementById("loginError").textContent = "Invalid username or password";
+    return;
+  }
+  window.location.reload();
+});
+
+// getJSON fetches an API endpoint, and returns the parsed JSON
+async function getJSON(path, params) {
+  const query = new URLSearchParams(params || {});
+  const res = await fetch(path + "?" + query.toString());
+  if (res.status === 401) {
+    showLogin();
+  }
+  if (!res.ok) {
+    const body = await res.json().catch(() => ({}));
+    throw new Error(body.message || res.statusText);
+  }
+  return res.json();
+}
+
+// formatDate returns the date as 2006-01-02 in local time
+function formatDate(d) {
+  const pad = (n) => String(n).padStart(2, "0");
+  return d.getFullYear() + "-" + pad(d.getMonth() + 1) + "-" + pad(d.getDate());
+}
+
+// renderBars draws a bar chart, each item needs a value, a label and optionally a class
+function renderBars(element, items) {
+  element.replaceChildren();
+  if (items.length === 0) {
+    const empty = document.createElement("span");
+    empty.className = "empty";
+    empty.textContent = "No data";
+    element.appendChild(empty);
+    return;
+  }
+
+  const max = Math.max(...items.map((i) => i.value), 0.0001);
+  for (const item of items) {
+    const bar = document.createElement("div");
+    bar.className = "bar " + (item.className || "");
+    bar.style.height = Math.max(0, (item.value / max) * 100) + "%";
+    bar.dataset.label = item.label;
+    element.appendChild(bar);
+  }
+}
+
+// renderError shows an error in place of a chart
+function renderError(element, err) {
+  element.replaceChildren();
+  const msg = document.createElement("span");
+  msg.className = "empty";
+  msg.textContent = "Error: " + err.message;
+  element.appendChild(msg);
+}
+
+async function loadPrices(meteringPointId) {
+  const element = document.getElementById("prices");
+  try {
+    const params = meteringPointId ? { meteringPointId } : {};
+    const res = await getJSON("prices", params);
+    const now = new Date();
+    const today = formatDate(now);
+    renderBars(element, res.prices.map((p) => {
+      const hour = new Date(p.hour);
+      let className = formatDate(hour) === today ? "" : "tomorrow";
+      if (hour <= now && now - hour < 3600 * 1000) {
+        className = "now";
+      }
+      return {
+        value: p.total,
+        label: hour.toLocaleString([], { weekday: "short", hour: "2-digit", minute: "2-digit" }) + ": " + p.total.toFixed(1) + " øre",
+        className,
+      };
+    }));
+  } catch (err) {
+    renderError(element, err);
+  }
+}
+
+async function loadUsageAndCost(meteringPointId) {
+  const usageElement = document.getElementById("usage");
+  const costElement = document.getElementById("cost");
+  if (!meteringPointId) {
+    renderBars(usageElement, []);
+    renderBars(costElement, []);
+    return;
+  }
+
+  const to = new Date();
+  const from = new Date(to.getFullYear(), to.getMonth(), to.getDate() - 30);
+  const params = { meteringPointId, from: formatDate(from), to: formatDate(to) };
+
+  try {
+    const usage = await getJSON("usage", Object.assign({ resolution: "day" }, params));
+    document.getElementById("usageTotal").textContent = usage.total.toFixed(1) + " " + usage.unit;
+    renderBars(usageElement, usage.usage.map((u) => ({
+      value: u.quantity,
+      label: formatDate(new Date(u.time)) + ": " + u.quantity.toFixed(2) + " " + usage.unit,
+    })));
+  } catch (err) {
+    renderError(usageElement, err);
+  }
+
+  try {
+    const cost = await getJSON("cost", params);
+    document.getElementById("costTotal").textContent = cost.total.toFixed(2) + " " + cost.currency;
+    renderBars(costElement, cost.days.map((d) => ({
+      value: d.cost,
+      label: formatDate(new Date(d.time)) + ": " + d.cost.toFixed(2) + " " + cost.currency,
+    })));
+  } catch (err) {
+    renderError(costElement, err);
+  }
+}
+
+function load() {
+  const meteringPointId = meteringPointSelect.value;
+  loadPrices(meteringPointId);
+  loadUsageAndCost(meteringPointId);
+}
+
+async function init() {
+  try {
+    const mps = await getJSON("meteringpoints");
+    for (const mp of mps) {
+      const option = document.createElement("option");
+      option.value = mp.meteringPointId;
+      option.textContent = [mp.streetName, mp.buildingNumber, mp.cityName].filter(Boolean).join(" ") || mp.meteringPointId;
+      meteringPointSelect.appendChild(option);
+    }
+  } catch (err) {
+    console.error("unable to get meteringpoints:", err);
+  }
+
+  meteringPointSelect.addEventListener("change", load);
+  load();
+}
+
+init();